@@ -0,0 +1,21 @@
+package glare
+
+import (
+	"time"
+)
+
+// Announcement represents a single announcement resource from the Layer API.
+// Announcements are messages sent by the system to users outside of any
+// conversation.
+type Announcement struct {
+	ID         AnnouncementID `json:"id,omitempty"`
+	URL        string         `json:"url"`
+	Parts      []MessagePart  `json:"parts"`
+	Recipients []string       `json:"recipients"`
+	SentAt     *time.Time     `json:"sent_at,omitempty"`
+	IsUnread   bool           `json:"is_unread"`
+	Sender     struct {
+		Name   string `json:"name,omitempty"`
+		UserID string `json:"user_id,omitempty"`
+	} `json:"sender"`
+}
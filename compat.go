@@ -0,0 +1,237 @@
+package glare
+
+// The flat methods below predate the resource scoped accessors such as
+// Layer.Conversations and Layer.AsUser. They are kept so that existing callers
+// and the service interfaces keep working, and simply forward to the scoped
+// methods.
+
+// -----------------------------------------------------------------------------
+// ------------------------- Conversation Methods ------------------------------
+// -----------------------------------------------------------------------------
+
+// GetConversationsByUser is equivalent to l.AsUser(userID).Conversations().
+func (l Layer) GetConversationsByUser(userID string) ([]Conversation, error) {
+	return l.AsUser(userID).Conversations()
+}
+
+// GetConversationByUser is equivalent to l.AsUser(userID).Conversation(conversationID).
+func (l Layer) GetConversationByUser(userID string, conversationID ConversationID) (Conversation, error) {
+	return l.AsUser(userID).Conversation(conversationID)
+}
+
+// GetConversationByID is equivalent to l.Conversations().Get(conversationID).
+func (l Layer) GetConversationByID(conversationID ConversationID) (Conversation, error) {
+	return l.Conversations().Get(conversationID)
+}
+
+// CreateConversation is equivalent to l.Conversations().Create(pending).
+func (l Layer) CreateConversation(pending Conversation) (Conversation, error) {
+	return l.Conversations().Create(pending)
+}
+
+// EditConversation is equivalent to l.Conversations().Edit(c, changes).
+func (l Layer) EditConversation(c Conversation, changes []EditRequest) (Conversation, error) {
+	return l.Conversations().Edit(c, changes)
+}
+
+// DeleteConversation is equivalent to l.Conversations().Delete(remove).
+func (l Layer) DeleteConversation(remove Conversation) error {
+	return l.Conversations().Delete(remove)
+}
+
+// -----------------------------------------------------------------------------
+// ---------------------------- Message Methods --------------------------------
+// -----------------------------------------------------------------------------
+
+// SendMessage is equivalent to l.Messages().Send(m, c).
+func (l Layer) SendMessage(m Message, c Conversation) (Message, error) {
+	return l.Messages().Send(m, c)
+}
+
+// GetMessage is equivalent to l.Messages().Get(id).
+func (l Layer) GetMessage(id MessageID) (Message, error) {
+	return l.Messages().Get(id)
+}
+
+// GetMessageByUser is equivalent to l.AsUser(userID).Message(id).
+func (l Layer) GetMessageByUser(userID string, id MessageID) (Message, error) {
+	return l.AsUser(userID).Message(id)
+}
+
+// RetrieveMessages is equivalent to l.Messages().List(c, pageSize, fromID).
+func (l Layer) RetrieveMessages(c Conversation, pageSize int, fromID MessageID) ([]Message, error) {
+	return l.Messages().List(c, pageSize, fromID)
+}
+
+// RetrieveMessagesByUser is equivalent to l.AsUser(userID).Messages(c).
+func (l Layer) RetrieveMessagesByUser(userID string, c Conversation) ([]Message, error) {
+	return l.AsUser(userID).Messages(c)
+}
+
+// DeleteMessage is equivalent to l.Messages().Delete(m, c, "").
+func (l Layer) DeleteMessage(m Message, c Conversation) error {
+	return l.Messages().Delete(m, c, "")
+}
+
+// DeleteMessageWithMode is equivalent to l.Messages().Delete(m, c, mode).
+func (l Layer) DeleteMessageWithMode(m Message, c Conversation, mode string) error {
+	return l.Messages().Delete(m, c, mode)
+}
+
+// DeleteMessageByUser is equivalent to l.AsUser(userID).DeleteMessage(m, mode).
+func (l Layer) DeleteMessageByUser(userID string, m Message, mode string) error {
+	return l.AsUser(userID).DeleteMessage(m, mode)
+}
+
+// AddMessagePart is equivalent to l.Messages().AddPart(m, part).
+func (l Layer) AddMessagePart(m Message, part MessagePart) (MessagePart, error) {
+	return l.Messages().AddPart(m, part)
+}
+
+// UpdateMessagePart is equivalent to l.Messages().UpdatePart(m, part).
+func (l Layer) UpdateMessagePart(m Message, part MessagePart) error {
+	return l.Messages().UpdatePart(m, part)
+}
+
+// DeleteMessagePart is equivalent to l.Messages().DeletePart(m, part).
+func (l Layer) DeleteMessagePart(m Message, part MessagePart) error {
+	return l.Messages().DeletePart(m, part)
+}
+
+// MarkMessageRead is equivalent to l.AsUser(userID).MarkRead(m).
+func (l Layer) MarkMessageRead(userID string, m Message) error {
+	return l.AsUser(userID).MarkRead(m)
+}
+
+// MarkMessageDelivered is equivalent to l.AsUser(userID).MarkDelivered(m).
+func (l Layer) MarkMessageDelivered(userID string, m Message) error {
+	return l.AsUser(userID).MarkDelivered(m)
+}
+
+// SendReceipt is equivalent to l.AsUser(userID).SendReceipt(m, receiptType).
+func (l Layer) SendReceipt(userID string, m Message, receiptType string) error {
+	return l.AsUser(userID).SendReceipt(m, receiptType)
+}
+
+// MarkAllMessagesRead is equivalent to l.AsUser(userID).MarkAllRead(c, position).
+func (l Layer) MarkAllMessagesRead(userID string, c Conversation, position int64) error {
+	return l.AsUser(userID).MarkAllRead(c, position)
+}
+
+// GetUnreadMessageCount is equivalent to l.AsUser(userID).UnreadCount(c).
+func (l Layer) GetUnreadMessageCount(userID string, c Conversation) (int, error) {
+	return l.AsUser(userID).UnreadCount(c)
+}
+
+// GetUnreadMessageCounts is equivalent to l.AsUser(userID).UnreadCounts().
+func (l Layer) GetUnreadMessageCounts(userID string) (map[string]int, error) {
+	return l.AsUser(userID).UnreadCounts()
+}
+
+// -----------------------------------------------------------------------------
+// --------------------------- Identity Methods --------------------------------
+// -----------------------------------------------------------------------------
+
+// RegisterIdentity is equivalent to l.Identities().Register(id, i).
+func (l Layer) RegisterIdentity(id string, i Identity) error {
+	return l.Identities().Register(id, i)
+}
+
+// UpdateIdentity is equivalent to l.Identities().Update(id, changes...).
+func (l Layer) UpdateIdentity(id string, changes ...EditRequest) (Identity, error) {
+	return l.Identities().Update(id, changes...)
+}
+
+// UpsertIdentity is equivalent to l.Identities().Upsert(id, i).
+func (l Layer) UpsertIdentity(id string, i Identity) (bool, error) {
+	return l.Identities().Upsert(id, i)
+}
+
+// RetrieveIdentity is equivalent to l.Identities().Get(id).
+func (l Layer) RetrieveIdentity(id string) (Identity, error) {
+	return l.Identities().Get(id)
+}
+
+// DeleteIdentity is equivalent to l.Identities().Delete(id).
+func (l Layer) DeleteIdentity(id string) error {
+	return l.Identities().Delete(id)
+}
+
+// RetrieveBadge is equivalent to l.AsUser(id).Badge().
+func (l Layer) RetrieveBadge(id string) (Badge, error) {
+	return l.AsUser(id).Badge()
+}
+
+// SetBadge is equivalent to l.AsUser(id).SetBadge(externalUnreadCount).
+func (l Layer) SetBadge(id string, externalUnreadCount int) error {
+	return l.AsUser(id).SetBadge(externalUnreadCount)
+}
+
+// FollowIdentity is equivalent to l.AsUser(id).Follow(followID).
+func (l Layer) FollowIdentity(id string, followID string) error {
+	return l.AsUser(id).Follow(followID)
+}
+
+// UnfollowIdentity is equivalent to l.AsUser(id).Unfollow(followID).
+func (l Layer) UnfollowIdentity(id string, followID string) error {
+	return l.AsUser(id).Unfollow(followID)
+}
+
+// ListFollowedIdentities is equivalent to l.AsUser(id).Following().
+func (l Layer) ListFollowedIdentities(id string) ([]string, error) {
+	return l.AsUser(id).Following()
+}
+
+// SetFollowedIdentities is equivalent to l.AsUser(id).SetFollowing(followIDs).
+func (l Layer) SetFollowedIdentities(id string, followIDs []string) error {
+	return l.AsUser(id).SetFollowing(followIDs)
+}
+
+// ListBlockedIdentities is equivalent to l.AsUser(id).Blocked().
+func (l Layer) ListBlockedIdentities(id string) ([]Identity, error) {
+	return l.AsUser(id).Blocked()
+}
+
+// BlockIdentity is equivalent to l.AsUser(id).Block(blockID).
+func (l Layer) BlockIdentity(id string, blockID string) error {
+	return l.AsUser(id).Block(blockID)
+}
+
+// UnblockIdentity is equivalent to l.AsUser(id).Unblock(blockID).
+func (l Layer) UnblockIdentity(id string, blockID string) error {
+	return l.AsUser(id).Unblock(blockID)
+}
+
+// -----------------------------------------------------------------------------
+// ---------------------------- WebHook Methods --------------------------------
+// -----------------------------------------------------------------------------
+
+// RegisterWebHook is equivalent to l.WebHooks().Register(created).
+func (l Layer) RegisterWebHook(created WebHook) (WebHook, error) {
+	return l.WebHooks().Register(created)
+}
+
+// ListWebHooks is equivalent to l.WebHooks().List().
+func (l Layer) ListWebHooks() ([]WebHook, error) {
+	return l.WebHooks().List()
+}
+
+// GetWebHook is equivalent to l.WebHooks().Get(id).
+func (l Layer) GetWebHook(id string) (WebHook, error) {
+	return l.WebHooks().Get(id)
+}
+
+// ActivateWebHook is equivalent to l.WebHooks().Activate(w).
+func (l Layer) ActivateWebHook(w WebHook) (WebHook, error) {
+	return l.WebHooks().Activate(w)
+}
+
+// DeactivateWebHook is equivalent to l.WebHooks().Deactivate(w).
+func (l Layer) DeactivateWebHook(w WebHook) (WebHook, error) {
+	return l.WebHooks().Deactivate(w)
+}
+
+// DeleteWebHook is equivalent to l.WebHooks().Delete(w).
+func (l Layer) DeleteWebHook(w WebHook) error {
+	return l.WebHooks().Delete(w)
+}
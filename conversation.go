@@ -6,7 +6,7 @@ import (
 
 // Conversation represents a single conversation resource from the Layer API
 type Conversation struct {
-	ID                 string                 `json:"id,omitempty"`
+	ID                 ConversationID         `json:"id,omitempty"`
 	URL                string                 `json:"url"`
 	MessagesURL        string                 `json:"messages_url"`
 	CreatedAt          *time.Time             `json:"created_at,omitempty"`
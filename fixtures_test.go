@@ -0,0 +1,146 @@
+package glare_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtreleaven/glare"
+	"github.com/jtreleaven/glare/glaretest"
+)
+
+// The cassettes in testdata/cassettes are fixtures written by hand from the
+// Layer API documentation, not recordings of the live API. The tests below only
+// check that the glare resources agree with those fixtures: they pin the
+// documented shape of each resource and cannot reveal drift in the live API,
+// so they are not contract tests. Recording real cassettes, by sending
+// requests through a glaretest.Recorder set as the transport of
+// Backoff.Client, is still to be done.
+
+// TestFixtureShapes should strictly decode every successful response in the
+// fixtures into the glare resource for its route, so that the resources keep a
+// field for everything the documented responses contain.
+func TestFixtureShapes(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "cassettes", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No fixtures found: %v\n", err)
+	}
+
+	covered := make(map[string]bool)
+	for _, file := range files {
+		cassette, err := glaretest.LoadCassette(file)
+		if err != nil {
+			t.Fatalf("%s\n", err)
+		}
+
+		for i, interaction := range cassette.Interactions {
+			res := interaction.Response
+			if res.StatusCode < 200 || res.StatusCode > 299 || len(res.Body) == 0 {
+				continue
+			}
+
+			name, target := fixtureTarget(interaction.Request)
+			if target == nil {
+				t.Logf("%s #%d: no fixture for %s %s\n", file, i, interaction.Request.Method, interaction.Request.URL)
+				t.Fail()
+				continue
+			}
+
+			decoder := json.NewDecoder(bytes.NewReader(res.Body))
+			decoder.DisallowUnknownFields()
+			if err = decoder.Decode(target); err != nil {
+				t.Logf("%s #%d: response does not match %s: %s\n", file, i, name, err)
+				t.Fail()
+				continue
+			}
+			covered[name] = true
+		}
+	}
+
+	for _, name := range []string{"Conversation", "Message", "Identity", "WebHook"} {
+		if !covered[name] && !covered["[]"+name] {
+			t.Logf("No fixture covers %s\n", name)
+			t.Fail()
+		}
+	}
+}
+
+// TestFixtureReplay should serve the glare client methods from the fixtures.
+func TestFixtureReplay(t *testing.T) {
+	cassette := &glaretest.Cassette{}
+	for _, name := range []string{"conversations", "messages", "identities", "webhooks"} {
+		c, err := glaretest.LoadCassette(filepath.Join("testdata", "cassettes", name+".json"))
+		if err != nil {
+			t.Fatalf("%s\n", err)
+		}
+		cassette.Interactions = append(cassette.Interactions, c.Interactions...)
+	}
+
+	l := glare.New("app", "token", "3.0", glare.Backoff{})
+	l.Backoff.Client = glaretest.NewReplayer(cassette).Client()
+
+	c, err := l.GetConversationByID("layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67")
+	if err != nil || c.MetaData["title"] != "Lunch" || c.LastMessage.Sender.UserID != "alice" {
+		t.Logf("Unexpected conversation %+v: %v\n", c, err)
+		t.Fail()
+	}
+
+	messages, err := l.RetrieveMessages(c, 1, "")
+	if err != nil || len(messages) != 1 || len(messages[0].Parts) != 2 {
+		t.Logf("Unexpected messages %+v: %v\n", messages, err)
+		t.Fail()
+	}
+
+	i, err := l.RetrieveIdentity("alice")
+	if err != nil || i.UserID != "alice" || i.Presence == nil {
+		t.Logf("Unexpected identity %+v: %v\n", i, err)
+		t.Fail()
+	}
+
+	webhooks, err := l.ListWebHooks()
+	if err != nil || len(webhooks) != 1 || webhooks[0].Status != glare.WebHookStatusActive {
+		t.Logf("Unexpected webhooks %+v: %v\n", webhooks, err)
+		t.Fail()
+	}
+}
+
+// fixtureTarget returns the resource a successful response to the request
+// decodes into, or nil if the route has no fixture.
+func fixtureTarget(req glaretest.RecordedRequest) (string, interface{}) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return "", nil
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 3 || segments[0] != "apps" {
+		return "", nil
+	}
+	route := segments[2:]
+	if route[0] == "users" && len(route) > 2 {
+		route = route[2:]
+	}
+
+	last := route[len(route)-1]
+	switch {
+	case last == "identity":
+		return "Identity", &glare.Identity{}
+	case route[0] == "webhooks" && len(route) == 1 && req.Method == "GET":
+		return "[]WebHook", &[]glare.WebHook{}
+	case route[0] == "webhooks":
+		return "WebHook", &glare.WebHook{}
+	case last == "messages" && req.Method == "GET":
+		return "[]Message", &[]glare.Message{}
+	case last == "messages" || route[0] == "messages":
+		return "Message", &glare.Message{}
+	case route[0] == "conversations" && len(route) == 1 && req.Method == "GET":
+		return "[]Conversation", &[]glare.Conversation{}
+	case route[0] == "conversations":
+		return "Conversation", &glare.Conversation{}
+	}
+
+	return "", nil
+}
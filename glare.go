@@ -10,10 +10,12 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
-const baseURL = "https://api.layer.com"
+// defaultBaseURL is the Layer API host used when Layer.BaseURL is not set.
+const defaultBaseURL = "https://api.layer.com"
 
 var client = &http.Client{}
 
@@ -24,23 +26,40 @@ type EditRequest struct {
 	Value     interface{} `json:"value"`
 }
 
+// Operations supported by an EditRequest.
+const (
+	EditSet    = "set"
+	EditDelete = "delete"
+	EditAdd    = "add"
+	EditRemove = "remove"
+)
+
 // Layer is the primary struct that acts as the receiver for the API methods
 type Layer struct {
 	ID      string
 	Token   string
 	Version string
 	Backoff Backoff
+	// BaseURL overrides the Layer API host, for example to point at a test server.
+	BaseURL string
 }
 
 // Backoff is a configuration to use when implementing exponential backoff. If numTries is 1 or 0 then no backoff will be performed.
 type Backoff struct {
 	NumTries int
 	MinTime  int
-	MaxTime  int
-	logger   *log.Logger
+	// MaxTime caps every wait in milliseconds, including the Retry-After
+	// Layer asks for when rate limiting.
+	MaxTime int
+	// Client sends the requests, for example with a fault injecting transport
+	// in tests. Defaults to a shared http.Client.
+	Client *http.Client
+	logger *log.Logger
 }
 
 // ExtractUUID returns the 36 character uuid value at the end of a layer id.
+// It performs no validation; prefer the typed IDs such as ConversationID and
+// MessageID which check the resource kind and UUID.
 func ExtractUUID(id string) string {
 	if len(id) < 36 {
 		return id
@@ -53,6 +72,15 @@ func New(id string, token string, version string, backoff Backoff) Layer {
 	return Layer{ID: id, Token: token, Version: version, Backoff: backoff}
 }
 
+// baseURL returns the host all requests made by the client are sent to.
+func (l Layer) baseURL() string {
+	if len(l.BaseURL) > 0 {
+		return strings.TrimSuffix(l.BaseURL, "/")
+	}
+
+	return defaultBaseURL
+}
+
 // NewBackoff returns a new Backoff configuration to be used with the Layer client.
 func NewBackoff(numTries, minTime, maxTime int, logger *log.Logger) Backoff {
 	return Backoff{
@@ -63,16 +91,82 @@ func NewBackoff(numTries, minTime, maxTime int, logger *log.Logger) Backoff {
 	}
 }
 
+// ConversationsClient groups the conversation methods of the Layer API, acting
+// from the perspective of the system.
+type ConversationsClient struct {
+	l Layer
+}
+
+// MessagesClient groups the message methods of the Layer API, acting from the
+// perspective of the system.
+type MessagesClient struct {
+	l Layer
+}
+
+// IdentitiesClient groups the identity methods of the Layer API.
+type IdentitiesClient struct {
+	l Layer
+}
+
+// WebHooksClient groups the webhook methods of the Layer API.
+type WebHooksClient struct {
+	l Layer
+}
+
+// UserView groups the methods of the Layer API that act from the perspective
+// of a single user: their conversations, messages, receipts, badge, follows
+// and blocks.
+type UserView struct {
+	l      Layer
+	userID string
+}
+
+// Conversations returns the conversation methods of the client.
+func (l Layer) Conversations() ConversationsClient {
+	return ConversationsClient{l: l}
+}
+
+// Messages returns the message methods of the client.
+func (l Layer) Messages() MessagesClient {
+	return MessagesClient{l: l}
+}
+
+// Identities returns the identity methods of the client.
+func (l Layer) Identities() IdentitiesClient {
+	return IdentitiesClient{l: l}
+}
+
+// WebHooks returns the webhook methods of the client.
+func (l Layer) WebHooks() WebHooksClient {
+	return WebHooksClient{l: l}
+}
+
+// AsUser returns the methods of the client that act from the perspective of
+// the user matching userID, which may also be a full identity ID.
+func (l Layer) AsUser(userID string) UserView {
+	return UserView{l: l, userID: trimIdentityID(userID)}
+}
+
+// UserID returns the ID of the user the view acts as.
+func (u UserView) UserID() string {
+	return u.userID
+}
+
+// Identity will fetch the identity of the user.
+func (u UserView) Identity() (Identity, error) {
+	return u.l.Identities().Get(u.userID)
+}
+
 // -----------------------------------------------------------------------------
 // ------------------------- Conversation Methods ------------------------------
 // -----------------------------------------------------------------------------
 
-// GetConversationsByUser is the method for retrieving all conversations
+// Conversations is the method for retrieving all conversations
 // from the perspective of a user.
-func (l Layer) GetConversationsByUser(userID string) ([]Conversation, error) {
+func (u UserView) Conversations() ([]Conversation, error) {
 	var conversations []Conversation
-	url := fmt.Sprintf("%s/apps/%s/users/%s/conversations", baseURL, l.ID, userID)
-	res, err := makeLayerGetRequest(url, l.Token, l.Version, false, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/users/%s/conversations", u.l.baseURL(), u.l.ID, u.userID)
+	res, err := makeLayerGetRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
 	if err != nil {
 		return conversations, err
 	} else if res.StatusCode < 200 || res.StatusCode > 299 {
@@ -90,12 +184,12 @@ func (l Layer) GetConversationsByUser(userID string) ([]Conversation, error) {
 	return conversations, nil
 }
 
-// GetConversationByUser is the method for retrieving a conversation
+// Conversation is the method for retrieving a conversation
 // from the perspective of a user.
-func (l Layer) GetConversationByUser(userID string, conversationID string) (Conversation, error) {
+func (u UserView) Conversation(conversationID ConversationID) (Conversation, error) {
 	var conversation Conversation
-	url := fmt.Sprintf("%s/apps/%s/users/%s/conversations/%s", baseURL, l.ID, userID, conversationID)
-	res, err := makeLayerGetRequest(url, l.Token, l.Version, false, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/users/%s/conversations/%s", u.l.baseURL(), u.l.ID, u.userID, conversationID.UUID())
+	res, err := makeLayerGetRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
 	if err != nil {
 		return conversation, err
 	} else if res.StatusCode < 200 || res.StatusCode > 299 {
@@ -113,12 +207,12 @@ func (l Layer) GetConversationByUser(userID string, conversationID string) (Conv
 	return conversation, nil
 }
 
-// GetConversationByID is the method for retrieving a conversation from the
-// perspective of the system with only the conversation UUID
-func (l Layer) GetConversationByID(conversationID string) (Conversation, error) {
+// Get is the method for retrieving a conversation from the
+// perspective of the system with either the full conversation ID or its UUID
+func (cc ConversationsClient) Get(conversationID ConversationID) (Conversation, error) {
 	var conversation Conversation
-	url := fmt.Sprintf("%s/apps/%s/conversations/%s", baseURL, l.ID, conversationID)
-	res, err := makeLayerGetRequest(url, l.Token, l.Version, false, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/conversations/%s", cc.l.baseURL(), cc.l.ID, conversationID.UUID())
+	res, err := makeLayerGetRequest(url, cc.l.Token, cc.l.Version, false, cc.l.Backoff)
 	if err != nil {
 		return conversation, err
 	} else if res.StatusCode < 200 || res.StatusCode > 299 {
@@ -136,12 +230,12 @@ func (l Layer) GetConversationByID(conversationID string) (Conversation, error)
 	return conversation, nil
 }
 
-// CreateConversation will make a request to Layer for a new Conversation to
+// Create will make a request to Layer for a new Conversation to
 // be created using the given conversation object.
-func (l Layer) CreateConversation(pending Conversation) (Conversation, error) {
+func (cc ConversationsClient) Create(pending Conversation) (Conversation, error) {
 	var conversation Conversation
-	url := fmt.Sprintf("%s/apps/%s/conversations", baseURL, l.ID)
-	res, err := makeLayerPostRequest(url, l.Token, l.Version, false, false, pending, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/conversations", cc.l.baseURL(), cc.l.ID)
+	res, err := makeLayerPostRequest(url, cc.l.Token, cc.l.Version, false, false, pending, cc.l.Backoff)
 	if err != nil {
 		return conversation, err
 	}
@@ -157,12 +251,12 @@ func (l Layer) CreateConversation(pending Conversation) (Conversation, error) {
 	return conversation, nil
 }
 
-// EditConversation will make a request to Layer with an EditRequest body to
+// Edit will make a request to Layer with an EditRequest body to
 // modify the properties on the given conversation.
-func (l Layer) EditConversation(c Conversation, changes []EditRequest) (Conversation, error) {
+func (cc ConversationsClient) Edit(c Conversation, changes []EditRequest) (Conversation, error) {
 	var conversation Conversation
-	url := fmt.Sprintf("%s/apps/%s/conversations/%s", baseURL, l.ID, c.ID)
-	res, err := makeLayerPostRequest(url, l.Token, l.Version, true, false, changes, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/conversations/%s", cc.l.baseURL(), cc.l.ID, c.ID.UUID())
+	res, err := makeLayerPostRequest(url, cc.l.Token, cc.l.Version, true, false, changes, cc.l.Backoff)
 	if err != nil {
 		return conversation, err
 	} else if res.StatusCode != 200 && res.StatusCode != 201 {
@@ -177,26 +271,15 @@ func (l Layer) EditConversation(c Conversation, changes []EditRequest) (Conversa
 	return conversation, nil
 }
 
-// DeleteConversation will delete an existing conversation and applies
+// Delete will delete an existing conversation and applies
 // globally to all members of the conversation and across devices
-func (l Layer) DeleteConversation(remove Conversation) error {
-	url := fmt.Sprintf("%s/apps/%s/conversations/%s?mode=destroy", baseURL, l.ID, remove.ID)
-	res, err := makeLayerDeleteRequest(url, l.Token, l.Version, false, l.Backoff)
+func (cc ConversationsClient) Delete(remove Conversation) error {
+	url := fmt.Sprintf("%s/apps/%s/conversations/%s?mode=destroy", cc.l.baseURL(), cc.l.ID, remove.ID.UUID())
+	res, err := makeLayerDeleteRequest(url, cc.l.Token, cc.l.Version, false, cc.l.Backoff)
 	if err != nil {
 		return err
 	}
 
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		if res.StatusCode != 404 {
-			msg, err := ioutil.ReadAll(res.Body)
-			if err != nil {
-				return err
-			}
-
-			return fmt.Errorf("%d: %s", res.StatusCode, string(msg))
-		}
-	}
-
 	return res.Body.Close()
 }
 
@@ -204,12 +287,12 @@ func (l Layer) DeleteConversation(remove Conversation) error {
 // ---------------------------- Message Methods --------------------------------
 // -----------------------------------------------------------------------------
 
-// SendMessage will take the given Message object and Post that data to the
+// Send will take the given Message object and Post that data to the
 // Layer API for the given conversation.
-func (l Layer) SendMessage(m Message, c Conversation) (Message, error) {
+func (mc MessagesClient) Send(m Message, c Conversation) (Message, error) {
 	var message Message
-	url := fmt.Sprintf("%s/apps/%s/conversations/%s/messages", baseURL, l.ID, c.ID)
-	res, err := makeLayerPostRequest(url, l.Token, l.Version, false, false, m, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/conversations/%s/messages", mc.l.baseURL(), mc.l.ID, c.ID.UUID())
+	res, err := makeLayerPostRequest(url, mc.l.Token, mc.l.Version, false, false, m, mc.l.Backoff)
 	if err != nil {
 		return message, err
 	} else if res.StatusCode != 201 {
@@ -226,9 +309,56 @@ func (l Layer) SendMessage(m Message, c Conversation) (Message, error) {
 	return message, nil
 }
 
-// RetrieveMessages will return a slice of messages from the given conversation
+// Get will retrieve a single message from the perspective of the system.
+// The id may be either a full layer:///messages/<uuid> ID or a bare UUID.
+func (mc MessagesClient) Get(id MessageID) (Message, error) {
+	var message Message
+	url := fmt.Sprintf("%s/apps/%s/messages/%s", mc.l.baseURL(), mc.l.ID, id.UUID())
+	res, err := makeLayerGetRequest(url, mc.l.Token, mc.l.Version, false, mc.l.Backoff)
+	if err != nil {
+		return message, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		return message, fmt.Errorf("Status Code: %d, Status: %s\n%+v\n", res.StatusCode, res.Status, res)
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&message); err != nil {
+		return message, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return message, err
+	}
+
+	return message, nil
+}
+
+// Message will retrieve a single message from the perspective of the
+// user. The id may be either a full layer:///messages/<uuid> ID or a
+// bare UUID.
+func (u UserView) Message(id MessageID) (Message, error) {
+	var message Message
+	url := fmt.Sprintf("%s/apps/%s/users/%s/messages/%s", u.l.baseURL(), u.l.ID, u.userID, id.UUID())
+	res, err := makeLayerGetRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
+	if err != nil {
+		return message, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		return message, fmt.Errorf("Status Code: %d, Status: %s\n%+v\n", res.StatusCode, res.Status, res)
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&message); err != nil {
+		return message, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return message, err
+	}
+
+	return message, nil
+}
+
+// List will return a slice of messages from the given conversation
 // which pertains to the System perspective.
-func (l Layer) RetrieveMessages(c Conversation, pageSize int, fromID string) ([]Message, error) {
+func (mc MessagesClient) List(c Conversation, pageSize int, fromID MessageID) ([]Message, error) {
 	var messages []Message
 
 	// Collect potential query params for navigating pages.
@@ -238,11 +368,11 @@ func (l Layer) RetrieveMessages(c Conversation, pageSize int, fromID string) ([]
 	}
 
 	if len(fromID) > 0 {
-		params.Add("from_id", fromID)
+		params.Add("from_id", fromID.String())
 	}
 
-	url := fmt.Sprintf("%s/apps/%s/conversations/%s/messages?%s", baseURL, l.ID, c.ID, params.Encode())
-	res, err := makeLayerGetRequest(url, l.Token, l.Version, false, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/conversations/%s/messages?%s", mc.l.baseURL(), mc.l.ID, c.ID.UUID(), params.Encode())
+	res, err := makeLayerGetRequest(url, mc.l.Token, mc.l.Version, false, mc.l.Backoff)
 	if err != nil {
 		return messages, err
 	} else if res.StatusCode < 200 || res.StatusCode > 299 {
@@ -259,12 +389,12 @@ func (l Layer) RetrieveMessages(c Conversation, pageSize int, fromID string) ([]
 	return messages, nil
 }
 
-// RetrieveMessagesByUser will return a slice of message objects that are
-// associated to the given userID and conversation
-func (l Layer) RetrieveMessagesByUser(userID string, c Conversation) ([]Message, error) {
+// Messages will return a slice of message objects that are
+// associated to the user and the given conversation
+func (u UserView) Messages(c Conversation) ([]Message, error) {
 	var messages []Message
-	url := fmt.Sprintf("%s/apps/%s/users/%s/conversations/%s/messages", baseURL, l.ID, userID, c.ID)
-	res, err := makeLayerGetRequest(url, l.Token, l.Version, false, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/users/%s/conversations/%s/messages", u.l.baseURL(), u.l.ID, u.userID, c.ID.UUID())
+	res, err := makeLayerGetRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
 	if err != nil {
 		return messages, err
 	} else if res.StatusCode != 200 {
@@ -282,45 +412,192 @@ func (l Layer) RetrieveMessagesByUser(userID string, c Conversation) ([]Message,
 	return messages, nil
 }
 
-// DeleteMessage will delete the given message from the given conversation.
-func (l Layer) DeleteMessage(m Message, c Conversation) error {
-	url := fmt.Sprintf("%s/apps/%s/conversations/%s/messages/%s", baseURL, l.ID, c.ID, m.ID)
-	res, err := makeLayerDeleteRequest(url, l.Token, l.Version, false, l.Backoff)
+// Delete will delete the given message from the given
+// conversation using the given deletion mode. An empty mode leaves the
+// choice to Layer.
+func (mc MessagesClient) Delete(m Message, c Conversation, mode string) error {
+	url := fmt.Sprintf("%s/apps/%s/conversations/%s/messages/%s", mc.l.baseURL(), mc.l.ID, c.ID.UUID(), m.ID.UUID())
+	if len(mode) > 0 {
+		url = fmt.Sprintf("%s?mode=%s", url, mode)
+	}
+	res, err := makeLayerDeleteRequest(url, mc.l.Token, mc.l.Version, false, mc.l.Backoff)
 	if err != nil {
 		return err
 	}
 
+	return res.Body.Close()
+}
+
+// DeleteMessage will delete the given message from the perspective of the
+// user using the given deletion mode. An empty mode leaves the choice to
+// Layer.
+func (u UserView) DeleteMessage(m Message, mode string) error {
+	url := fmt.Sprintf("%s/apps/%s/users/%s/messages/%s", u.l.baseURL(), u.l.ID, u.userID, m.ID.UUID())
+	if len(mode) > 0 {
+		url = fmt.Sprintf("%s?mode=%s", url, mode)
+	}
+	res, err := makeLayerDeleteRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// AddPart will append a new part to an existing message. Editing
+// messages requires version 3.0 or later of the Layer API.
+func (mc MessagesClient) AddPart(m Message, part MessagePart) (MessagePart, error) {
+	var created MessagePart
+	if !supportsMessageEditing(mc.l.Version) {
+		return created, fmt.Errorf("Editing messages is not supported by Layer API version %s", mc.l.Version)
+	}
+
+	url := fmt.Sprintf("%s/apps/%s/messages/%s/parts", mc.l.baseURL(), mc.l.ID, m.ID.UUID())
+	res, err := makeLayerPostRequest(url, mc.l.Token, mc.l.Version, false, false, part, mc.l.Backoff)
+	if err != nil {
+		return created, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&created); err != nil {
+		return created, err
+	}
+
 	if err = res.Body.Close(); err != nil {
+		return created, err
+	}
+
+	return created, nil
+}
+
+// UpdatePart will replace the contents of an existing part of the given
+// message. Editing messages requires version 3.0 or later of the Layer API.
+func (mc MessagesClient) UpdatePart(m Message, part MessagePart) error {
+	if !supportsMessageEditing(mc.l.Version) {
+		return fmt.Errorf("Editing messages is not supported by Layer API version %s", mc.l.Version)
+	}
+
+	url := fmt.Sprintf("%s/apps/%s/messages/%s/parts/%s", mc.l.baseURL(), mc.l.ID, m.ID.UUID(), lastSegment(part.ID))
+	res, err := makeLayerPutRequest(url, mc.l.Token, mc.l.Version, false, part, mc.l.Backoff)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return res.Body.Close()
+}
+
+// DeletePart will remove a single part from the given message. Editing
+// messages requires version 3.0 or later of the Layer API.
+func (mc MessagesClient) DeletePart(m Message, part MessagePart) error {
+	if !supportsMessageEditing(mc.l.Version) {
+		return fmt.Errorf("Editing messages is not supported by Layer API version %s", mc.l.Version)
+	}
+
+	url := fmt.Sprintf("%s/apps/%s/messages/%s/parts/%s", mc.l.baseURL(), mc.l.ID, m.ID.UUID(), lastSegment(part.ID))
+	res, err := makeLayerDeleteRequest(url, mc.l.Token, mc.l.Version, false, mc.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// MarkRead will mark the given message as read from the perspective of
+// the user.
+func (u UserView) MarkRead(m Message) error {
+	return u.SendReceipt(m, ReceiptRead)
+}
+
+// MarkDelivered will mark the given message as delivered from the
+// perspective of the user.
+func (u UserView) MarkDelivered(m Message) error {
+	return u.SendReceipt(m, ReceiptDelivered)
+}
+
+// SendReceipt will post a receipt of the given type (ReceiptRead or
+// ReceiptDelivered) for the message on behalf of the user.
+func (u UserView) SendReceipt(m Message, receiptType string) error {
+	url := fmt.Sprintf("%s/apps/%s/users/%s/messages/%s/receipts", u.l.baseURL(), u.l.ID, u.userID, m.ID.UUID())
+	res, err := makeLayerPostRequest(url, u.l.Token, u.l.Version, false, false, Receipt{Type: receiptType}, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// MarkAllRead will mark every message in the given conversation up to
+// and including position as read from the perspective of the user.
+func (u UserView) MarkAllRead(c Conversation, position int64) error {
+	body := struct {
+		Position int64 `json:"position"`
+	}{Position: position}
+	url := fmt.Sprintf("%s/apps/%s/users/%s/conversations/%s/mark_all_read", u.l.baseURL(), u.l.ID, u.userID, c.ID.UUID())
+	res, err := makeLayerPostRequest(url, u.l.Token, u.l.Version, false, false, body, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// UnreadCount will return the number of unread messages in the given
+// conversation from the perspective of the user.
+func (u UserView) UnreadCount(c Conversation) (int, error) {
+	conversation, err := u.Conversation(c.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return conversation.UnreadMessageCount, nil
+}
+
+// UnreadCounts will return the number of unread messages for each
+// of the user's conversations, keyed by conversation ID.
+func (u UserView) UnreadCounts() (map[string]int, error) {
+	counts := make(map[string]int)
+	conversations, err := u.Conversations()
+	if err != nil {
+		return counts, err
+	}
+
+	for _, c := range conversations {
+		counts[string(c.ID)] = c.UnreadMessageCount
+	}
+
+	return counts, nil
 }
 
 // -----------------------------------------------------------------------------
 // --------------------------- Identity Methods --------------------------------
 // -----------------------------------------------------------------------------
 
-// RegisterIdentity will create a new known user within Layer
-func (l Layer) RegisterIdentity(id string, i Identity) error {
-	url := fmt.Sprintf("%s/apps/%s/users/%s/identity", baseURL, l.ID, id)
-	res, err := makeLayerPostRequest(url, l.Token, l.Version, false, false, i, l.Backoff)
-	if err = res.Body.Close(); err != nil {
+// Register will create a new known user within Layer
+func (ic IdentitiesClient) Register(id string, i Identity) error {
+	url := fmt.Sprintf("%s/apps/%s/users/%s/identity", ic.l.baseURL(), ic.l.ID, trimIdentityID(id))
+	res, err := makeLayerPostRequest(url, ic.l.Token, ic.l.Version, false, false, i, ic.l.Backoff)
+	if err != nil {
 		return err
 	}
-	return err
+
+	return res.Body.Close()
 }
 
-// UpdateIdentity will change the Identity match the given id with the
-// new value passed into EditRequest.
-func (l Layer) UpdateIdentity(id string, changes EditRequest) (Identity, error) {
+// Update will change the Identity matching the given id by applying
+// every given EditRequest in a single patch. Nested metadata keys can be
+// targeted with properties of the form "metadata.<key>".
+func (ic IdentitiesClient) Update(id string, changes ...EditRequest) (Identity, error) {
 	var identity Identity
-	url := fmt.Sprintf("%s/apps/%s/users/%s/identity", baseURL, l.ID, id)
-	res, err := makeLayerPostRequest(url, l.Token, l.Version, true, false, changes, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/users/%s/identity", ic.l.baseURL(), ic.l.ID, trimIdentityID(id))
+	res, err := makeLayerPostRequest(url, ic.l.Token, ic.l.Version, true, false, changes, ic.l.Backoff)
 	if err != nil {
 		return identity, err
 	}
 
+	// Layer may acknowledge a patch without echoing the identity back.
+	if res.StatusCode == http.StatusNoContent {
+		return identity, res.Body.Close()
+	}
+
 	if err = json.NewDecoder(res.Body).Decode(&identity); err != nil {
 		return identity, err
 	}
@@ -332,11 +609,34 @@ func (l Layer) UpdateIdentity(id string, changes EditRequest) (Identity, error)
 	return identity, nil
 }
 
-// RetrieveIdentity will fetch the identity matching the given id from the Layer API
-func (l Layer) RetrieveIdentity(id string) (Identity, error) {
+// Upsert will register the identity matching the given id if it does
+// not exist yet, otherwise it patches only the fields that differ from the
+// identity currently stored in Layer. Fields and metadata keys left empty in
+// i are kept as they are, as described by MergeIdentityChanges; to clear them,
+// pass the changes of IdentityChanges to Update. It reports whether the
+// identity was created.
+func (ic IdentitiesClient) Upsert(id string, i Identity) (bool, error) {
+	current, err := ic.Get(id)
+	if isNotFound(err) {
+		return true, ic.Register(id, i)
+	} else if err != nil {
+		return false, err
+	}
+
+	changes := MergeIdentityChanges(current, i)
+	if len(changes) == 0 {
+		return false, nil
+	}
+
+	_, err = ic.Update(id, changes...)
+	return false, err
+}
+
+// Get will fetch the identity matching the given id from the Layer API
+func (ic IdentitiesClient) Get(id string) (Identity, error) {
 	var identity Identity
-	url := fmt.Sprintf("%s/apps/%s/users/%s/identity", baseURL, l.ID, id)
-	res, err := makeLayerGetRequest(url, l.Token, l.Version, false, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/users/%s/identity", ic.l.baseURL(), ic.l.ID, trimIdentityID(id))
+	res, err := makeLayerGetRequest(url, ic.l.Token, ic.l.Version, false, ic.l.Backoff)
 	if err != nil {
 		return identity, err
 	}
@@ -352,10 +652,10 @@ func (l Layer) RetrieveIdentity(id string) (Identity, error) {
 	return identity, nil
 }
 
-// DeleteIdentity will remove an Identity from Layer matching the given ID value
-func (l Layer) DeleteIdentity(id string) error {
-	url := fmt.Sprintf("%s/apps/%s/users/%s/identity", baseURL, l.ID, id)
-	res, err := makeLayerDeleteRequest(url, l.Token, l.Version, false, l.Backoff)
+// Delete will remove an Identity from Layer matching the given ID value
+func (ic IdentitiesClient) Delete(id string) error {
+	url := fmt.Sprintf("%s/apps/%s/users/%s/identity", ic.l.baseURL(), ic.l.ID, trimIdentityID(id))
+	res, err := makeLayerDeleteRequest(url, ic.l.Token, ic.l.Version, false, ic.l.Backoff)
 	if err != nil {
 		return err
 	}
@@ -365,16 +665,157 @@ func (l Layer) DeleteIdentity(id string) error {
 	return nil
 }
 
+// Badge will fetch the unread counts used for the push notification
+// badge of the user.
+func (u UserView) Badge() (Badge, error) {
+	var badge Badge
+	url := fmt.Sprintf("%s/apps/%s/users/%s/badge", u.l.baseURL(), u.l.ID, u.userID)
+	res, err := makeLayerGetRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
+	if err != nil {
+		return badge, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&badge); err != nil {
+		return badge, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return badge, err
+	}
+
+	return badge, nil
+}
+
+// SetBadge will set the external unread count of the user, which Layer adds
+// to its own unread count when computing the badge.
+func (u UserView) SetBadge(externalUnreadCount int) error {
+	url := fmt.Sprintf("%s/apps/%s/users/%s/badge", u.l.baseURL(), u.l.ID, u.userID)
+	res, err := makeLayerPutRequest(url, u.l.Token, u.l.Version, false, Badge{ExternalUnreadCount: externalUnreadCount}, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// Follow will make the user follow the user matching followID so that the
+// user receives that identity and its updates.
+func (u UserView) Follow(followID string) error {
+	url := fmt.Sprintf("%s/apps/%s/users/%s/identity/following/%s", u.l.baseURL(), u.l.ID, u.userID, trimIdentityID(followID))
+	res, err := makeLayerPutRequest(url, u.l.Token, u.l.Version, false, nil, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// Unfollow will make the user stop following the user matching followID.
+func (u UserView) Unfollow(followID string) error {
+	url := fmt.Sprintf("%s/apps/%s/users/%s/identity/following/%s", u.l.baseURL(), u.l.ID, u.userID, trimIdentityID(followID))
+	res, err := makeLayerDeleteRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// Following will return the user IDs of every identity followed
+// by the user.
+func (u UserView) Following() ([]string, error) {
+	var following []string
+	url := fmt.Sprintf("%s/apps/%s/users/%s/identity/following", u.l.baseURL(), u.l.ID, u.userID)
+	res, err := makeLayerGetRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
+	if err != nil {
+		return following, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&following); err != nil {
+		return following, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return following, err
+	}
+
+	return following, nil
+}
+
+// SetFollowing will replace the full list of identities followed by
+// the user with the given user IDs.
+func (u UserView) SetFollowing(followIDs []string) error {
+	following := make([]string, len(followIDs))
+	for i, id := range followIDs {
+		following[i] = trimIdentityID(id)
+	}
+
+	url := fmt.Sprintf("%s/apps/%s/users/%s/identity/following", u.l.baseURL(), u.l.ID, u.userID)
+	res, err := makeLayerPutRequest(url, u.l.Token, u.l.Version, false, following, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// Blocked will return the identities on the block list of the user.
+func (u UserView) Blocked() ([]Identity, error) {
+	var blocked []Identity
+	url := fmt.Sprintf("%s/apps/%s/users/%s/blocks", u.l.baseURL(), u.l.ID, u.userID)
+	res, err := makeLayerGetRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
+	if err != nil {
+		return blocked, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&blocked); err != nil {
+		return blocked, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return blocked, err
+	}
+
+	return blocked, nil
+}
+
+// Block will add the user matching blockID to the block list of the
+// user.
+func (u UserView) Block(blockID string) error {
+	body := struct {
+		UserID string `json:"user_id"`
+	}{UserID: trimIdentityID(blockID)}
+	url := fmt.Sprintf("%s/apps/%s/users/%s/blocks", u.l.baseURL(), u.l.ID, u.userID)
+	res, err := makeLayerPostRequest(url, u.l.Token, u.l.Version, false, false, body, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// Unblock will remove the user matching blockID from the block list of
+// the user.
+func (u UserView) Unblock(blockID string) error {
+	url := fmt.Sprintf("%s/apps/%s/users/%s/blocks/%s", u.l.baseURL(), u.l.ID, u.userID, trimIdentityID(blockID))
+	res, err := makeLayerDeleteRequest(url, u.l.Token, u.l.Version, false, u.l.Backoff)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
 // -----------------------------------------------------------------------------
 // ---------------------------- WebHook Methods --------------------------------
 // -----------------------------------------------------------------------------
 
-// RegisterWebHook will make a post request with the new webhook and return the
+// Register will make a post request with the new webhook and return the
 // newly created Layer API webhook object.
-func (l Layer) RegisterWebHook(created WebHook) (WebHook, error) {
+func (wc WebHooksClient) Register(created WebHook) (WebHook, error) {
 	var webhook WebHook
-	url := fmt.Sprintf("%s/apps/%s/webhooks", baseURL, l.ID)
-	res, err := makeLayerPostRequest(url, l.Token, l.Version, false, true, created, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/webhooks", wc.l.baseURL(), wc.l.ID)
+	res, err := makeLayerPostRequest(url, wc.l.Token, wc.l.Version, false, true, created, wc.l.Backoff)
 	if err != nil {
 		return webhook, err
 	}
@@ -390,11 +831,11 @@ func (l Layer) RegisterWebHook(created WebHook) (WebHook, error) {
 	return webhook, nil
 }
 
-// ListWebHooks will retrieve all existing WebHooks for your Layer Account.
-func (l Layer) ListWebHooks() ([]WebHook, error) {
+// List will retrieve all existing WebHooks for your Layer Account.
+func (wc WebHooksClient) List() ([]WebHook, error) {
 	var webhooks []WebHook
-	url := fmt.Sprintf("%s/apps/%s/webhooks", baseURL, l.ID)
-	res, err := makeLayerGetRequest(url, l.Token, l.Version, true, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/webhooks", wc.l.baseURL(), wc.l.ID)
+	res, err := makeLayerGetRequest(url, wc.l.Token, wc.l.Version, true, wc.l.Backoff)
 	if err != nil {
 		return webhooks, err
 	}
@@ -410,12 +851,12 @@ func (l Layer) ListWebHooks() ([]WebHook, error) {
 	return webhooks, nil
 }
 
-// GetWebHook will retrieve an existing WebHook from your Layer Account matching
+// Get will retrieve an existing WebHook from your Layer Account matching
 // the given ID.
-func (l Layer) GetWebHook(id string) (WebHook, error) {
+func (wc WebHooksClient) Get(id string) (WebHook, error) {
 	var webhook WebHook
-	url := fmt.Sprintf("%s/apps/%s/webhooks/%s", baseURL, l.ID, id)
-	res, err := makeLayerGetRequest(url, l.Token, l.Version, true, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/webhooks/%s", wc.l.baseURL(), wc.l.ID, id)
+	res, err := makeLayerGetRequest(url, wc.l.Token, wc.l.Version, true, wc.l.Backoff)
 	if err != nil {
 		return webhook, err
 	}
@@ -431,11 +872,11 @@ func (l Layer) GetWebHook(id string) (WebHook, error) {
 	return webhook, nil
 }
 
-// ActivateWebHook will make a request to Layer to activate the given WebHook
-func (l Layer) ActivateWebHook(w WebHook) (WebHook, error) {
+// Activate will make a request to Layer to activate the given WebHook
+func (wc WebHooksClient) Activate(w WebHook) (WebHook, error) {
 	var webhook WebHook
-	url := fmt.Sprintf("%s/apps/%s/webhooks/%s/activate", baseURL, l.ID, w.ID)
-	res, err := makeLayerPostRequest(url, l.Token, l.Version, false, true, w, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/webhooks/%s/activate", wc.l.baseURL(), wc.l.ID, w.ID)
+	res, err := makeLayerPostRequest(url, wc.l.Token, wc.l.Version, false, true, w, wc.l.Backoff)
 	if err != nil {
 		return webhook, err
 	}
@@ -451,12 +892,12 @@ func (l Layer) ActivateWebHook(w WebHook) (WebHook, error) {
 	return webhook, nil
 }
 
-// DeactivateWebHook will do the opposite of the activate function and deactivate
+// Deactivate will do the opposite of the activate function and deactivate
 // the given webhook to no longer be sent data
-func (l Layer) DeactivateWebHook(w WebHook) (WebHook, error) {
+func (wc WebHooksClient) Deactivate(w WebHook) (WebHook, error) {
 	var webhook WebHook
-	url := fmt.Sprintf("%s/apps/%s/webhooks/%s/deactivate", baseURL, l.ID, w.ID)
-	res, err := makeLayerPostRequest(url, l.Token, l.Version, false, true, w, l.Backoff)
+	url := fmt.Sprintf("%s/apps/%s/webhooks/%s/deactivate", wc.l.baseURL(), wc.l.ID, w.ID)
+	res, err := makeLayerPostRequest(url, wc.l.Token, wc.l.Version, false, true, w, wc.l.Backoff)
 	if err != nil {
 		return webhook, err
 	}
@@ -472,10 +913,10 @@ func (l Layer) DeactivateWebHook(w WebHook) (WebHook, error) {
 	return webhook, nil
 }
 
-// DeleteWebHook will remove the given WebHook instance from your Layer Account
-func (l Layer) DeleteWebHook(w WebHook) error {
-	url := fmt.Sprintf("%s/apps/%s/webhooks/%s", baseURL, l.ID, w.ID)
-	res, err := makeLayerDeleteRequest(url, l.Token, l.Version, true, l.Backoff)
+// Delete will remove the given WebHook instance from your Layer Account
+func (wc WebHooksClient) Delete(w WebHook) error {
+	url := fmt.Sprintf("%s/apps/%s/webhooks/%s", wc.l.baseURL(), wc.l.ID, w.ID)
+	res, err := makeLayerDeleteRequest(url, wc.l.Token, wc.l.Version, true, wc.l.Backoff)
 	if err != nil {
 		return err
 	}
@@ -486,6 +927,18 @@ func (l Layer) DeleteWebHook(w WebHook) error {
 	return nil
 }
 
+// RegisterAndActivate will register the webhook and wait for it to become
+// active, as described by Layer.RegisterAndActivateWebHook.
+func (wc WebHooksClient) RegisterAndActivate(created WebHook, responder *WebHookChallengeResponder, timeout time.Duration) (WebHook, error) {
+	return wc.l.RegisterAndActivateWebHook(created, responder, timeout)
+}
+
+// Reconcile will make the webhooks of the app match the desired ones, as
+// described by Layer.ReconcileWebHooks.
+func (wc WebHooksClient) Reconcile(desired []WebHook, opts ReconcileOptions) (WebHookPlan, error) {
+	return wc.l.ReconcileWebHooks(desired, opts)
+}
+
 // -----------------------------------------------------------------------------
 // --------------------------- PRIVATE FUNCTIONS -------------------------------
 // -----------------------------------------------------------------------------
@@ -530,6 +983,30 @@ func makeLayerPostRequest(url string, token string, version string, isPatch bool
 	return backoff.Do(req)
 }
 
+func makeLayerPutRequest(url string, token string, version string, isWebhook bool, body interface{}, backoff Backoff) (*http.Response, error) {
+	// Some PUT endpoints, like following an identity, take no body at all.
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return &http.Response{}, err
+		}
+	}
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(buf))
+	if err != nil {
+		return &http.Response{}, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	if isWebhook {
+		req.Header.Add("Accept", fmt.Sprintf("application/vnd.layer.webhooks+json; version=%s", version))
+	} else {
+		req.Header.Add("Accept", fmt.Sprintf("application/vnd.layer+json; version=%s", version))
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	return backoff.Do(req)
+}
+
 func makeLayerDeleteRequest(url string, token string, version string, isWebhook bool, backoff Backoff) (*http.Response, error) {
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
@@ -545,6 +1022,39 @@ func makeLayerDeleteRequest(url string, token string, version string, isWebhook
 	return backoff.Do(req)
 }
 
+// supportsMessageEditing reports whether the given Layer API version allows
+// message parts to be added, replaced or removed after sending.
+func supportsMessageEditing(version string) bool {
+	v, err := strconv.ParseFloat(version, 64)
+	if err != nil {
+		return false
+	}
+
+	return v >= 3.0
+}
+
+// errorStatusCode returns the status code of the last failed response wrapped
+// by the given error, or 0 if the error did not come from a Layer response.
+func errorStatusCode(err error) int {
+	switch e := err.(type) {
+	case httpError:
+		return e.statusCode
+	case errors:
+		for i := len(e) - 1; i >= 0; i-- {
+			if code := errorStatusCode(e[i]); code != 0 {
+				return code
+			}
+		}
+	}
+
+	return 0
+}
+
+// isNotFound reports whether the given error was caused by a 404 from Layer.
+func isNotFound(err error) bool {
+	return errorStatusCode(err) == http.StatusNotFound
+}
+
 type httpError struct {
 	body       string
 	statusCode int
@@ -553,6 +1063,30 @@ type httpError struct {
 
 type errors []error
 
+// parseRetryAfter returns how long a rate limited or unavailable response asks
+// us to wait before retrying, given either in seconds or as an HTTP date. Dates
+// in the past and invalid values give no delay.
+func parseRetryAfter(res *http.Response) time.Duration {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+
+	header := res.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil || time.Until(date) < 0 {
+		return 0
+	}
+
+	return time.Until(date)
+}
+
 func newHttpError(res *http.Response, latency int64) httpError {
 	body, _ := ioutil.ReadAll(res.Body)
 	return httpError{
@@ -589,8 +1123,14 @@ func (b Backoff) Do(req *http.Request) (*http.Response, error) {
 	var counter int
 	var errs errors
 	var reqBody []byte
+	var retryAfter time.Duration
 	loop := true
 
+	httpClient := b.Client
+	if httpClient == nil {
+		httpClient = client
+	}
+
 	// We need to store the request body so that we can reset it after each backoff attempt.
 	if req.Body != nil {
 		reqBody, _ = ioutil.ReadAll(req.Body)
@@ -614,12 +1154,22 @@ func (b Backoff) Do(req *http.Request) (*http.Response, error) {
 				waitTime = exponential
 			}
 
-			time.Sleep(time.Duration(waitTime) * time.Millisecond)
+			// Layer may ask us to wait longer when rate limiting, but never
+			// longer than MaxTime so that a bad header cannot stall us.
+			wait := time.Duration(waitTime) * time.Millisecond
+			if maxWait := time.Duration(b.MaxTime) * time.Millisecond; retryAfter > maxWait {
+				retryAfter = maxWait
+			}
+			if retryAfter > wait {
+				wait = retryAfter
+			}
+
+			time.Sleep(wait)
 		}
 
 		// Grabbing system time in milliseconds to calculate latency.
 		startTime := time.Now().UnixNano() / 1000000
-		res, err := client.Do(req)
+		res, err := httpClient.Do(req)
 		latency := (time.Now().UnixNano() / 1000000) - startTime
 
 		// Have to make sure we have a response object before peeling the status code.
@@ -652,9 +1202,11 @@ func (b Backoff) Do(req *http.Request) (*http.Response, error) {
 			if res.StatusCode > 199 && res.StatusCode < 399 {
 				return res, nil
 			} else {
+				retryAfter = parseRetryAfter(res)
 				errs = append(errs, newHttpError(res, latency))
 			}
 		} else {
+			retryAfter = 0
 			// If something goes wrong with the request itself (rather than a bas status code) we should also push that into errs.
 			errs = append(errs, err)
 		}
@@ -1,9 +1,13 @@
 package glare
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jarcoal/httpmock"
 )
@@ -29,7 +33,7 @@ func TestGetConversationsByUserSuccess(t *testing.T) {
 		},
 	)
 
-	l := New("123", "fjghfjshryfbus", "1.0")
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
 	convos, err := l.GetConversationsByUser("B")
 	if err != nil {
 		t.Log(err)
@@ -45,3 +49,848 @@ func TestGetConversationsByUserSuccess(t *testing.T) {
 	t.Log("Success!")
 	return
 }
+
+// TestGetMessageSuccess should accept both a full layer message id and a bare
+// uuid and resolve them to the same message resource.
+func TestGetMessageSuccess(t *testing.T) {
+	mockResult := Message{ID: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67", URL: "localhost", IsUnread: true}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/messages/940de862-3c96-11e4-baad-164230d1df67",
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, mockResult)
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	for _, id := range []MessageID{mockResult.ID, "940de862-3c96-11e4-baad-164230d1df67"} {
+		message, err := l.GetMessage(id)
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		if !reflect.DeepEqual(message, mockResult) {
+			t.Log("Handled response is different that response given...")
+			t.Logf("%+v\n", message)
+			t.Fail()
+		}
+	}
+}
+
+// TestParseConversationID should accept full and bare conversation ids and
+// reject ids belonging to other resources or without a valid uuid.
+func TestParseConversationID(t *testing.T) {
+	full := "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"
+	for _, input := range []string{full, "f3cc7b32-3c92-11e4-baad-164230d1df67"} {
+		id, err := ParseConversationID(input)
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		if id.String() != full || id.UUID() != "f3cc7b32-3c92-11e4-baad-164230d1df67" {
+			t.Logf("Unexpected id parsed from %s: %s\n", input, id)
+			t.Fail()
+		}
+	}
+
+	for _, input := range []string{"", "1", "layer:///messages/f3cc7b32-3c92-11e4-baad-164230d1df67"} {
+		if _, err := ParseConversationID(input); err == nil {
+			t.Logf("Expected %q to be rejected\n", input)
+			t.Fail()
+		}
+	}
+}
+
+// TestConversationIDJSON should normalize valid ids to their full form and keep
+// unexpected ids as they are instead of failing the decode.
+func TestConversationIDJSON(t *testing.T) {
+	cases := map[string]ConversationID{
+		`"f3cc7b32-3c92-11e4-baad-164230d1df67"`:                        "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67",
+		`"layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"`: "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67",
+		`"1"`: "1",
+		`"layer:///messages/940de862-3c96-11e4-baad-164230d1df67"`: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67",
+		`null`: "",
+	}
+
+	for input, expected := range cases {
+		var id ConversationID
+		if err := json.Unmarshal([]byte(input), &id); err != nil || id != expected {
+			t.Logf("Decoding %s gave %q: %v\n", input, id, err)
+			t.Fail()
+		}
+
+		buf, err := json.Marshal(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded ConversationID
+		if err = json.Unmarshal(buf, &decoded); err != nil || decoded != id {
+			t.Logf("Round trip of %q gave %q: %v\n", id, decoded, err)
+			t.Fail()
+		}
+	}
+}
+
+// TestIdentityMethodsAcceptIdentityID should resolve full identity ids to the
+// user id in identity and user perspective requests.
+func TestIdentityMethodsAcceptIdentityID(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/identity",
+		httpmock.NewStringResponder(200, `{"id": "layer:///identities/B", "user_id": "B"}`),
+	)
+	httpmock.RegisterResponder("PUT", "https://api.layer.com/apps/123/users/B/identity/following/C",
+		httpmock.NewStringResponder(204, ""),
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	var id IdentityID = "layer:///identities/B"
+	if _, err := l.RetrieveIdentity(id.String()); err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if err := l.FollowIdentity(id.String(), "layer:///identities/C"); err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+}
+
+// TestListBlockedIdentitiesSuccess should return the typed identities on a
+// user's block list.
+func TestListBlockedIdentitiesSuccess(t *testing.T) {
+	mockResult := []Identity{
+		{ID: "layer:///identities/C", URL: "https://api.layer.com/identities/C", DisplayName: "C"},
+		{ID: "layer:///identities/D", URL: "https://api.layer.com/identities/D", DisplayName: "D"},
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/blocks",
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, mockResult)
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	blocked, err := l.ListBlockedIdentities("B")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if !reflect.DeepEqual(blocked, mockResult) {
+		t.Log("Handled response is different that response given...")
+		t.Logf("%+v\n", blocked)
+		t.Fail()
+	}
+
+	if blocked[0].ID.UserID() != "C" {
+		t.Logf("Unexpected user id %s\n", blocked[0].ID.UserID())
+		t.Fail()
+	}
+}
+
+// TestBlockIdentitySuccess should post the blocked user id to the block list
+// of the given user.
+func TestBlockIdentitySuccess(t *testing.T) {
+	var body struct {
+		UserID string `json:"user_id"`
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/users/B/blocks",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	if err := l.BlockIdentity("B", "C"); err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if body.UserID != "C" {
+		t.Logf("Expected blocked user C, got %q\n", body.UserID)
+		t.Fail()
+	}
+}
+
+// TestIdentityChanges should only emit edits for fields and metadata keys that
+// differ between the two identities.
+func TestIdentityChanges(t *testing.T) {
+	current := Identity{
+		DisplayName: "Frodo",
+		FirstName:   "Frodo",
+		Email:       "frodo@shire.com",
+		MetaData:    map[string]interface{}{"ring": "one", "home": "Bag End"},
+	}
+	desired := Identity{
+		DisplayName: "Mr. Underhill",
+		FirstName:   "Frodo",
+		MetaData:    map[string]interface{}{"ring": "one", "age": "50"},
+	}
+
+	expected := []EditRequest{
+		{Operation: EditSet, Property: "display_name", Value: "Mr. Underhill"},
+		{Operation: EditDelete, Property: "email_address"},
+		{Operation: EditSet, Property: "metadata.age", Value: "50"},
+		{Operation: EditDelete, Property: "metadata.home"},
+	}
+
+	changes := IdentityChanges(current, desired)
+	if !reflect.DeepEqual(changes, expected) {
+		t.Logf("Unexpected changes: %+v\n", changes)
+		t.Fail()
+	}
+
+	if changes := IdentityChanges(current, current); len(changes) != 0 {
+		t.Logf("Expected no changes, got %+v\n", changes)
+		t.Fail()
+	}
+
+	// Metadata read back from Layer holds float64 numbers and generic maps.
+	current.MetaData = map[string]interface{}{"age": float64(50), "address": map[string]interface{}{"number": float64(1)}}
+	desired = current
+	desired.MetaData = map[string]interface{}{"age": 50, "address": map[string]int{"number": 1}}
+	if changes := IdentityChanges(current, desired); len(changes) != 0 {
+		t.Logf("Expected numbers to match across types, got %+v\n", changes)
+		t.Fail()
+	}
+}
+
+// TestAsUserUnreadCounts should request the conversations of the user the
+// view acts as, and match the flat method it replaces.
+func TestAsUserUnreadCounts(t *testing.T) {
+	mockResult := []Conversation{
+		{ID: "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67", UnreadMessageCount: 3},
+		{ID: "layer:///conversations/5f2a3b6e-3c93-11e4-baad-164230d1df67", UnreadMessageCount: 0},
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/conversations",
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, mockResult)
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	counts, err := l.AsUser("B").UnreadCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{string(mockResult[0].ID): 3, string(mockResult[1].ID): 0}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Logf("Unexpected unread counts: %+v\n", counts)
+		t.Fail()
+	}
+
+	flat, err := l.GetUnreadMessageCounts("B")
+	if err != nil || !reflect.DeepEqual(flat, counts) {
+		t.Logf("Flat method returned %+v: %v\n", flat, err)
+		t.Fail()
+	}
+}
+
+// TestIdentityChangesPublicKey should set a new public key but never delete an
+// existing one when the desired identity leaves it empty.
+func TestIdentityChangesPublicKey(t *testing.T) {
+	current := Identity{DisplayName: "Frodo", PublicKey: "ssh-rsa AAAA"}
+
+	if changes := IdentityChanges(current, Identity{DisplayName: "Frodo"}); len(changes) != 0 {
+		t.Logf("Expected the public key to be left alone, got %+v\n", changes)
+		t.Fail()
+	}
+
+	expected := []EditRequest{{Operation: EditSet, Property: "public_key", Value: "ssh-rsa BBBB"}}
+	if changes := IdentityChanges(current, Identity{DisplayName: "Frodo", PublicKey: "ssh-rsa BBBB"}); !reflect.DeepEqual(changes, expected) {
+		t.Logf("Unexpected changes: %+v\n", changes)
+		t.Fail()
+	}
+}
+
+// TestMergeIdentityChanges should only set the fields and metadata keys of the
+// desired identity, never deleting anything.
+func TestMergeIdentityChanges(t *testing.T) {
+	current := Identity{
+		DisplayName: "Frodo",
+		Email:       "frodo@shire.com",
+		MetaData:    map[string]interface{}{"ring": "one", "home": "Bag End"},
+	}
+	desired := Identity{
+		DisplayName: "Mr. Underhill",
+		MetaData:    map[string]interface{}{"ring": "one", "age": "50"},
+	}
+
+	expected := []EditRequest{
+		{Operation: EditSet, Property: "display_name", Value: "Mr. Underhill"},
+		{Operation: EditSet, Property: "metadata.age", Value: "50"},
+	}
+	if changes := MergeIdentityChanges(current, desired); !reflect.DeepEqual(changes, expected) {
+		t.Logf("Unexpected changes: %+v\n", changes)
+		t.Fail()
+	}
+
+	if changes := MergeIdentityChanges(current, Identity{}); len(changes) != 0 {
+		t.Logf("Expected no changes, got %+v\n", changes)
+		t.Fail()
+	}
+}
+
+// TestRetrieveIdentityFields should decode the identity type, public key and
+// presence reported by Layer.
+func TestRetrieveIdentityFields(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/identity",
+		httpmock.NewStringResponder(200, `{
+			"id": "layer:///identities/B",
+			"user_id": "B",
+			"display_name": "Bilbo",
+			"public_key": "ssh-rsa AAAA",
+			"identity_type": "bot",
+			"presence": {"status": "away", "last_seen_at": "2016-05-12T19:50:12Z"}
+		}`),
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	identity, err := l.RetrieveIdentity("B")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity.PublicKey != "ssh-rsa AAAA" || identity.IdentityType != IdentityTypeBot {
+		t.Logf("Unexpected identity %+v\n", identity)
+		t.Fail()
+	}
+
+	if identity.Presence == nil || identity.Presence.Status != PresenceAway || identity.Presence.LastSeenAt == nil {
+		t.Logf("Unexpected presence %+v\n", identity.Presence)
+		t.Fail()
+	}
+}
+
+// TestRetrieveBadgeSuccess should decode the unread counts of a user's badge.
+func TestRetrieveBadgeSuccess(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/badge",
+		httpmock.NewStringResponder(200, `{"external_unread_count": 2, "unread_conversation_count": 3, "unread_message_count": 7}`),
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	badge, err := l.RetrieveBadge("B")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Badge{ExternalUnreadCount: 2, UnreadConversationCount: 3, UnreadMessageCount: 7}
+	if badge != expected {
+		t.Logf("Unexpected badge %+v\n", badge)
+		t.Fail()
+	}
+}
+
+// TestSetBadgeSuccess should put the external unread count of the user.
+func TestSetBadgeSuccess(t *testing.T) {
+	var body map[string]interface{}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("PUT", "https://api.layer.com/apps/123/users/B/badge",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	if err := l.SetBadge("B", 4); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"external_unread_count": float64(4)}
+	if !reflect.DeepEqual(body, expected) {
+		t.Logf("Unexpected badge body %+v\n", body)
+		t.Fail()
+	}
+}
+
+// TestDeleteMessageModes should only send a mode when one is given, including
+// when deleting from the perspective of a user.
+func TestDeleteMessageModes(t *testing.T) {
+	var queries []string
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	record := func(req *http.Request) (*http.Response, error) {
+		queries = append(queries, req.URL.RawQuery)
+		return httpmock.NewStringResponse(204, ""), nil
+	}
+	httpmock.RegisterResponder("DELETE", "https://api.layer.com/apps/123/conversations/f3cc7b32-3c92-11e4-baad-164230d1df67/messages/940de862-3c96-11e4-baad-164230d1df67", record)
+	httpmock.RegisterResponder("DELETE", "https://api.layer.com/apps/123/users/B/messages/940de862-3c96-11e4-baad-164230d1df67", record)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	m := Message{ID: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67"}
+	c := Conversation{ID: "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"}
+
+	if err := l.DeleteMessage(m, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.DeleteMessageWithMode(m, c, DeleteAllParticipants); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.DeleteMessageWithMode(m, c, DeleteMyDevices); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.DeleteMessageByUser("B", m, DeleteUserPerspective); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.DeleteMessageByUser("B", m, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"", "mode=all_participants", "mode=my_devices", "mode=user_perspective", ""}
+	if !reflect.DeepEqual(queries, expected) {
+		t.Logf("Unexpected delete queries %q\n", queries)
+		t.Fail()
+	}
+}
+
+// TestDeleteMessageStatus should ignore a missing message but report any
+// other failure.
+func TestDeleteMessageStatus(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	m := Message{ID: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67"}
+	c := Conversation{ID: "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"}
+	url := "https://api.layer.com/apps/123/conversations/f3cc7b32-3c92-11e4-baad-164230d1df67/messages/940de862-3c96-11e4-baad-164230d1df67"
+
+	httpmock.RegisterResponder("DELETE", url, httpmock.NewStringResponder(404, ""))
+	if err := l.DeleteMessage(m, c); err != nil {
+		t.Logf("Expected a 404 to be ignored, got %v\n", err)
+		t.Fail()
+	}
+
+	httpmock.RegisterResponder("DELETE", url, httpmock.NewStringResponder(500, "boom"))
+	err := l.DeleteMessage(m, c)
+	if errorStatusCode(err) != 500 || !strings.Contains(err.Error(), "boom") {
+		t.Logf("Expected the 500 to be reported, got %v\n", err)
+		t.Fail()
+	}
+}
+
+// TestMessagePartsSuccess should add, replace and remove parts of a message.
+func TestMessagePartsSuccess(t *testing.T) {
+	var added, updated MessagePart
+	var deleted bool
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/messages/940de862-3c96-11e4-baad-164230d1df67/parts",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&added); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			return httpmock.NewStringResponse(201, `{"id": "layer:///messages/940de862-3c96-11e4-baad-164230d1df67/parts/1", "mime_type": "text/plain", "body": "hello"}`), nil
+		},
+	)
+	httpmock.RegisterResponder("PUT", "https://api.layer.com/apps/123/messages/940de862-3c96-11e4-baad-164230d1df67/parts/1",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&updated); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+	httpmock.RegisterResponder("DELETE", "https://api.layer.com/apps/123/messages/940de862-3c96-11e4-baad-164230d1df67/parts/1",
+		func(req *http.Request) (*http.Response, error) {
+			deleted = true
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "3.0", Backoff{})
+	m := Message{ID: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67"}
+
+	part, err := l.AddMessagePart(m, MessagePart{MimeType: "text/plain", Body: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Body != "hello" || part.ID != "layer:///messages/940de862-3c96-11e4-baad-164230d1df67/parts/1" {
+		t.Logf("Unexpected part %+v sent as %+v\n", part, added)
+		t.Fail()
+	}
+
+	part.Body = "goodbye"
+	if err = l.UpdateMessagePart(m, part); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Body != "goodbye" {
+		t.Logf("Unexpected replacement part %+v\n", updated)
+		t.Fail()
+	}
+
+	if err = l.DeleteMessagePart(m, part); err != nil || !deleted {
+		t.Logf("Expected the part to be deleted: %v\n", err)
+		t.Fail()
+	}
+}
+
+// TestMessagePartsVersion should refuse to edit messages before version 3.0
+// of the Layer API without making a request.
+func TestMessagePartsVersion(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	l := New("123", "fjghfjshryfbus", "2.0", Backoff{})
+	m := Message{ID: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67"}
+	part := MessagePart{ID: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67/parts/1"}
+
+	if _, err := l.AddMessagePart(m, part); err == nil || !strings.Contains(err.Error(), "2.0") {
+		t.Logf("Expected AddMessagePart to fail, got %v\n", err)
+		t.Fail()
+	}
+	if err := l.UpdateMessagePart(m, part); err == nil {
+		t.Log("Expected UpdateMessagePart to fail")
+		t.Fail()
+	}
+	if err := l.DeleteMessagePart(m, part); err == nil {
+		t.Log("Expected DeleteMessagePart to fail")
+		t.Fail()
+	}
+}
+
+// TestSendReceiptSuccess should post read and delivered receipts for a message
+// on behalf of the user.
+func TestSendReceiptSuccess(t *testing.T) {
+	var receipts []Receipt
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/users/B/messages/940de862-3c96-11e4-baad-164230d1df67/receipts",
+		func(req *http.Request) (*http.Response, error) {
+			var receipt Receipt
+			if err := json.NewDecoder(req.Body).Decode(&receipt); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			receipts = append(receipts, receipt)
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	m := Message{ID: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67"}
+	if err := l.MarkMessageRead("B", m); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.MarkMessageDelivered("B", m); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Receipt{{Type: ReceiptRead}, {Type: ReceiptDelivered}}
+	if !reflect.DeepEqual(receipts, expected) {
+		t.Logf("Unexpected receipts %+v\n", receipts)
+		t.Fail()
+	}
+}
+
+// TestMarkAllMessagesReadSuccess should send the position up to which the
+// conversation is read.
+func TestMarkAllMessagesReadSuccess(t *testing.T) {
+	var body map[string]interface{}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/users/B/conversations/f3cc7b32-3c92-11e4-baad-164230d1df67/mark_all_read",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	c := Conversation{ID: "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"}
+	if err := l.MarkAllMessagesRead("B", c, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]interface{}{"position": float64(42)}; !reflect.DeepEqual(body, expected) {
+		t.Logf("Unexpected mark_all_read body %+v\n", body)
+		t.Fail()
+	}
+}
+
+// TestGetUnreadMessageCountSuccess should read the unread count of a single
+// conversation from the perspective of the user.
+func TestGetUnreadMessageCountSuccess(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/conversations/f3cc7b32-3c92-11e4-baad-164230d1df67",
+		httpmock.NewStringResponder(200, `{"id": "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67", "unread_message_count": 7}`),
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	c := Conversation{ID: "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"}
+	count, err := l.GetUnreadMessageCount("B", c)
+	if err != nil || count != 7 {
+		t.Logf("Expected 7 unread messages, got %d: %v\n", count, err)
+		t.Fail()
+	}
+}
+
+// TestFollowIdentitySuccess should follow an identity with a PUT that has no
+// body.
+func TestFollowIdentitySuccess(t *testing.T) {
+	var body []byte
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("PUT", "https://api.layer.com/apps/123/users/B/identity/following/C",
+		func(req *http.Request) (*http.Response, error) {
+			body, _ = ioutil.ReadAll(req.Body)
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	if err := l.FollowIdentity("B", "C"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body) != 0 {
+		t.Logf("Expected no body, got %q\n", body)
+		t.Fail()
+	}
+}
+
+// TestSetFollowedIdentitiesEmpty should send an empty list, rather than null,
+// when a user stops following everyone.
+func TestSetFollowedIdentitiesEmpty(t *testing.T) {
+	var body []byte
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("PUT", "https://api.layer.com/apps/123/users/B/identity/following",
+		func(req *http.Request) (*http.Response, error) {
+			body, _ = ioutil.ReadAll(req.Body)
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	if err := l.SetFollowedIdentities("B", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "[]" {
+		t.Logf("Expected an empty list, got %q\n", body)
+		t.Fail()
+	}
+}
+
+// TestUnfollowIdentityNotFound should treat unfollowing an identity that is not
+// followed as a success.
+func TestUnfollowIdentityNotFound(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("DELETE", "https://api.layer.com/apps/123/users/B/identity/following/C",
+		httpmock.NewStringResponder(404, `{"id": "not_found"}`),
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	if err := l.UnfollowIdentity("B", "C"); err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+}
+
+// TestUpsertIdentityCreate should register an identity that does not exist
+// yet.
+func TestUpsertIdentityCreate(t *testing.T) {
+	var registered Identity
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/identity",
+		httpmock.NewStringResponder(404, `{"id": "not_found"}`),
+	)
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/users/B/identity",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&registered); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			return httpmock.NewStringResponse(201, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	created, err := l.UpsertIdentity("B", Identity{DisplayName: "Bilbo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !created || registered.DisplayName != "Bilbo" {
+		t.Logf("Expected Bilbo to be registered, created %v with %+v\n", created, registered)
+		t.Fail()
+	}
+}
+
+// TestUpsertIdentityPatch should only send the changed properties of an
+// existing identity, and nothing when it already matches.
+func TestUpsertIdentityPatch(t *testing.T) {
+	var patches [][]EditRequest
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/identity",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(200, `{"user_id": "B", "display_name": "Bilbo", "metadata": {"age": 111}}`), nil
+		},
+	)
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/users/B/identity",
+		func(req *http.Request) (*http.Response, error) {
+			var changes []EditRequest
+			if req.Header.Get("X-HTTP-Method-Override") != "PATCH" {
+				return httpmock.NewStringResponse(405, ""), nil
+			}
+			if err := json.NewDecoder(req.Body).Decode(&changes); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			patches = append(patches, changes)
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	created, err := l.UpsertIdentity("B", Identity{DisplayName: "Bilbo Baggins", MetaData: map[string]interface{}{"age": 111}})
+	if err != nil || created {
+		t.Fatalf("Expected an update, created %v: %v\n", created, err)
+	}
+
+	expected := [][]EditRequest{{{Operation: EditSet, Property: "display_name", Value: "Bilbo Baggins"}}}
+	if !reflect.DeepEqual(patches, expected) {
+		t.Logf("Unexpected patches %+v\n", patches)
+		t.Fail()
+	}
+
+	if _, err = l.UpsertIdentity("B", Identity{DisplayName: "Bilbo", MetaData: map[string]interface{}{"age": 111}}); err != nil || len(patches) != 1 {
+		t.Logf("Expected no patch for a matching identity, got %d: %v\n", len(patches), err)
+		t.Fail()
+	}
+
+	// A partial identity only sets its own fields.
+	if _, err = l.UpsertIdentity("B", Identity{Email: "bilbo@shire.com"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, []EditRequest{{Operation: EditSet, Property: "email_address", Value: "bilbo@shire.com"}})
+	if !reflect.DeepEqual(patches, expected) {
+		t.Logf("Unexpected patches %+v\n", patches)
+		t.Fail()
+	}
+}
+
+// TestBackoffRetryAfterCapped should never wait longer than MaxTime, however
+// long Layer asks us to wait.
+func TestBackoffRetryAfterCapped(t *testing.T) {
+	for _, retryAfter := range []string{"86400", time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat)} {
+		var calls int
+
+		httpmock.Activate()
+		httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/webhooks",
+			func(req *http.Request) (*http.Response, error) {
+				if calls++; calls == 1 {
+					res := httpmock.NewStringResponse(429, "")
+					res.Header.Set("Retry-After", retryAfter)
+					return res, nil
+				}
+				return httpmock.NewStringResponse(200, "[]"), nil
+			},
+		)
+
+		l := New("123", "fjghfjshryfbus", "1.0", NewBackoff(1, 1, 20, nil))
+		start := time.Now()
+		if _, err := l.ListWebHooks(); err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+		if elapsed := time.Since(start); elapsed > time.Second || calls != 2 {
+			t.Logf("Retry-After %q: retried %d times after %s\n", retryAfter, calls, elapsed)
+			t.Fail()
+		}
+		httpmock.DeactivateAndReset()
+	}
+}
+
+// TestParseRetryAfter should accept seconds and HTTP dates, ignoring dates in
+// the past.
+func TestParseRetryAfter(t *testing.T) {
+	cases := map[string]time.Duration{
+		"120":  2 * time.Minute,
+		"-5":   0,
+		"soon": 0,
+		time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat): 0,
+	}
+
+	for header, expected := range cases {
+		res := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{header}}}
+		if wait := parseRetryAfter(res); wait != expected {
+			t.Logf("Retry-After %q gave %s, expected %s\n", header, wait, expected)
+			t.Fail()
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	res := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": []string{future}}}
+	if wait := parseRetryAfter(res); wait < 58*time.Minute || wait > time.Hour {
+		t.Logf("Retry-After %q gave %s\n", future, wait)
+		t.Fail()
+	}
+}
@@ -0,0 +1,265 @@
+//go:build ignore
+// +build ignore
+
+// gen.go writes services.go, a mock for every interface declared in the
+// services.go file of the glare package. Run it with go generate.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// source is the file declaring the interfaces to mock, relative to glaremock.
+const source = "../services.go"
+
+func main() {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, filepath.Dir(source), nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pkg, ok := pkgs["glare"]
+	if !ok {
+		log.Fatal("gen: package glare not found")
+	}
+
+	// The zero value of a named type depends on its underlying type, which
+	// may be declared in any file of the package.
+	types := make(map[string]ast.Expr)
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok {
+				for _, spec := range gen.Specs {
+					if t, ok := spec.(*ast.TypeSpec); ok {
+						types[t.Name.Name] = t.Type
+					}
+				}
+			}
+		}
+	}
+
+	services, ok := pkg.Files[source]
+	if !ok {
+		log.Fatalf("gen: %s not found", source)
+	}
+
+	g := generator{types: types, imports: map[string]bool{"github.com/jtreleaven/glare": true}}
+	for _, decl := range services.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			t := spec.(*ast.TypeSpec)
+			if iface, ok := t.Type.(*ast.InterfaceType); ok {
+				g.mock(t.Name.Name, iface)
+			}
+		}
+	}
+
+	out, err := format.Source(g.file())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile("services.go", out, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type generator struct {
+	types   map[string]ast.Expr
+	imports map[string]bool
+	body    bytes.Buffer
+}
+
+// file returns the generated source, before formatting.
+func (g *generator) file() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen.go; DO NOT EDIT.\n\npackage glaremock\n\nimport (\n")
+
+	var imports []string
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	for _, path := range imports {
+		if !strings.Contains(path, ".") {
+			fmt.Fprintf(&buf, "\t%q\n", path)
+		}
+	}
+	buf.WriteString("\n")
+	for _, path := range imports {
+		if strings.Contains(path, ".") {
+			fmt.Fprintf(&buf, "\t%q\n", path)
+		}
+	}
+	buf.WriteString(")\n")
+	buf.Write(g.body.Bytes())
+
+	return buf.Bytes()
+}
+
+// mock writes the mock struct of the named interface and its methods.
+func (g *generator) mock(name string, iface *ast.InterfaceType) {
+	g.body.WriteString("\n")
+	g.comment(fmt.Sprintf("%s is a mock glare.%s. Each method records its call and delegates to the matching Func field, or fails with ErrNotMocked when the field is nil.", name, name))
+	fmt.Fprintf(&g.body, "type %s struct {\n", name)
+	for _, method := range iface.Methods.List {
+		fn := method.Type.(*ast.FuncType)
+		fmt.Fprintf(&g.body, "\t%sFunc func%s\n", method.Names[0].Name, g.signature(fn))
+	}
+	g.body.WriteString("\n\tcalls\n}\n")
+
+	for _, method := range iface.Methods.List {
+		g.method(name, method.Names[0].Name, method.Type.(*ast.FuncType))
+	}
+}
+
+// method writes a single mock method.
+func (g *generator) method(mock string, name string, fn *ast.FuncType) {
+	var args, call []string
+	for i, param := range fn.Params.List {
+		names := param.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{ast.NewIdent(fmt.Sprintf("arg%d", i))}
+		}
+		for _, n := range names {
+			args = append(args, n.Name)
+			if _, variadic := param.Type.(*ast.Ellipsis); variadic {
+				call = append(call, n.Name+"...")
+			} else {
+				call = append(call, n.Name)
+			}
+		}
+	}
+
+	var zeros []string
+	if fn.Results != nil {
+		for _, result := range fn.Results.List {
+			if ident, ok := result.Type.(*ast.Ident); ok && ident.Name == "error" {
+				zeros = append(zeros, fmt.Sprintf("notMocked(%q)", name))
+			} else {
+				zeros = append(zeros, g.zero(result.Type))
+			}
+		}
+	}
+
+	recorded := append([]string{fmt.Sprintf("%q", name)}, args...)
+	fmt.Fprintf(&g.body, "\n// %s implements glare.%s.\n", name, mock)
+	fmt.Fprintf(&g.body, "func (mock *%s) %s%s {\n", mock, name, g.signature(fn))
+	fmt.Fprintf(&g.body, "\tmock.record(%s)\n", strings.Join(recorded, ", "))
+	fmt.Fprintf(&g.body, "\tif mock.%sFunc == nil {\n\t\treturn %s\n\t}\n\n", name, strings.Join(zeros, ", "))
+	fmt.Fprintf(&g.body, "\treturn mock.%sFunc(%s)\n}\n", name, strings.Join(call, ", "))
+}
+
+// signature renders the parameters and results of a function, qualifying the
+// types of the glare package.
+func (g *generator) signature(fn *ast.FuncType) string {
+	var params []string
+	for i, param := range fn.Params.List {
+		names := param.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{ast.NewIdent(fmt.Sprintf("arg%d", i))}
+		}
+		for _, n := range names {
+			params = append(params, n.Name+" "+g.expr(param.Type))
+		}
+	}
+
+	var results []string
+	if fn.Results != nil {
+		for _, result := range fn.Results.List {
+			results = append(results, g.expr(result.Type))
+		}
+	}
+
+	switch len(results) {
+	case 0:
+		return fmt.Sprintf("(%s)", strings.Join(params, ", "))
+	case 1:
+		return fmt.Sprintf("(%s) %s", strings.Join(params, ", "), results[0])
+	}
+
+	return fmt.Sprintf("(%s) (%s)", strings.Join(params, ", "), strings.Join(results, ", "))
+}
+
+// expr renders a type expression as seen from the glaremock package.
+func (g *generator) expr(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if _, ok := g.types[t.Name]; ok {
+			return "glare." + t.Name
+		}
+		return t.Name
+	case *ast.SelectorExpr:
+		pkg := t.X.(*ast.Ident).Name
+		g.imports[pkg] = true
+		return pkg + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + g.expr(t.X)
+	case *ast.ArrayType:
+		return "[]" + g.expr(t.Elt)
+	case *ast.MapType:
+		return fmt.Sprintf("map[%s]%s", g.expr(t.Key), g.expr(t.Value))
+	case *ast.Ellipsis:
+		return "..." + g.expr(t.Elt)
+	case *ast.InterfaceType:
+		return "interface{}"
+	}
+
+	log.Fatalf("gen: unsupported type %T", e)
+	return ""
+}
+
+// zero returns the zero value of a result type.
+func (g *generator) zero(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if underlying, ok := g.types[t.Name]; ok {
+			if _, isStruct := underlying.(*ast.StructType); isStruct {
+				return "glare." + t.Name + "{}"
+			}
+			return g.zero(underlying)
+		}
+
+		switch t.Name {
+		case "string":
+			return `""`
+		case "bool":
+			return "false"
+		case "error":
+			return "nil"
+		}
+		return "0"
+	case *ast.SelectorExpr:
+		return "0"
+	}
+
+	return "nil"
+}
+
+// comment writes text as a doc comment wrapped at 80 columns.
+func (g *generator) comment(text string) {
+	line := "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+1+len(word) > 80 {
+			g.body.WriteString(line + "\n")
+			line = "//"
+		}
+		line += " " + word
+	}
+	g.body.WriteString(line + "\n")
+}
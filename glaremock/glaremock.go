@@ -0,0 +1,91 @@
+// Package glaremock provides mocks of the glare service interfaces, so code
+// that depends on a glare.ConversationService, MessageService, IdentityService
+// or WebHookService, or on their scoped counterparts such as
+// glare.ScopedMessageService and glare.UserService, can be unit tested without
+// any HTTP. For a stateful fake of the Layer API itself, see the glaretest
+// package.
+//
+// The mocks in services.go are generated from the interfaces in the services.go
+// file of the glare package; run go generate after changing them.
+package glaremock
+
+//go:generate go run gen.go
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jtreleaven/glare"
+)
+
+// ErrNotMocked is returned by a mock method whose Func field is not set.
+var ErrNotMocked = errors.New("glaremock: method not mocked")
+
+var (
+	_ glare.ConversationService = &ConversationService{}
+	_ glare.MessageService      = &MessageService{}
+	_ glare.IdentityService     = &IdentityService{}
+	_ glare.WebHookService      = &WebHookService{}
+
+	_ glare.ScopedConversationService = &ScopedConversationService{}
+	_ glare.ScopedMessageService      = &ScopedMessageService{}
+	_ glare.ScopedIdentityService     = &ScopedIdentityService{}
+	_ glare.ScopedWebHookService      = &ScopedWebHookService{}
+	_ glare.UserService               = &UserService{}
+)
+
+// Call is a method call recorded by a mock.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// calls records the method calls of a mock. It is safe for concurrent use.
+type calls struct {
+	mu   sync.Mutex
+	list []Call
+}
+
+// Calls returns every recorded call, in order.
+func (c *calls) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Call(nil), c.list...)
+}
+
+// CallsTo returns the recorded calls of the given method, in order.
+func (c *calls) CallsTo(method string) []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matching []Call
+	for _, call := range c.list {
+		if call.Method == method {
+			matching = append(matching, call)
+		}
+	}
+
+	return matching
+}
+
+// ResetCalls forgets every recorded call.
+func (c *calls) ResetCalls() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.list = nil
+}
+
+func (c *calls) record(method string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.list = append(c.list, Call{Method: method, Args: args})
+}
+
+// notMocked wraps ErrNotMocked with the name of the method.
+func notMocked(method string) error {
+	return fmt.Errorf("%s: %w", method, ErrNotMocked)
+}
@@ -0,0 +1,86 @@
+package glaremock
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jtreleaven/glare"
+)
+
+// greet is the kind of consumer code the mocks are meant for.
+func greet(conversations glare.ConversationService, messages glare.MessageService, userID string) error {
+	c, err := conversations.CreateConversation(glare.Conversation{Participants: []string{"bot", userID}, Distinct: true})
+	if err != nil {
+		return err
+	}
+
+	_, err = messages.SendMessage(glare.Message{Parts: []glare.MessagePart{{MimeType: "text/plain", Body: "Hello"}}}, c)
+	return err
+}
+
+// TestMocksRecordCalls should delegate to the Func fields and record every
+// call with its arguments.
+func TestMocksRecordCalls(t *testing.T) {
+	conversations := &ConversationService{
+		CreateConversationFunc: func(pending glare.Conversation) (glare.Conversation, error) {
+			pending.ID = "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"
+			return pending, nil
+		},
+	}
+	messages := &MessageService{
+		SendMessageFunc: func(m glare.Message, c glare.Conversation) (glare.Message, error) {
+			return m, nil
+		},
+	}
+
+	if err := greet(conversations, messages, "u1"); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	sent := messages.CallsTo("SendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected one SendMessage call, got %d\n", len(sent))
+	}
+
+	if c := sent[0].Args[1].(glare.Conversation); c.ID.UUID() != "f3cc7b32-3c92-11e4-baad-164230d1df67" {
+		t.Logf("Message sent to the wrong conversation %s\n", c.ID)
+		t.Fail()
+	}
+}
+
+// TestMocksNotMocked should fail calls to methods without a Func field.
+func TestMocksNotMocked(t *testing.T) {
+	messages := &MessageService{}
+	err := greet(&ConversationService{}, messages, "u1")
+	if !errors.Is(err, ErrNotMocked) {
+		t.Logf("Expected ErrNotMocked, got %v\n", err)
+		t.Fail()
+	}
+
+	if len(messages.Calls()) != 0 {
+		t.Logf("Expected no message calls, got %+v\n", messages.Calls())
+		t.Fail()
+	}
+}
+
+// TestScopedMocks should stand in for the scoped clients of a glare.Layer.
+func TestScopedMocks(t *testing.T) {
+	user := &UserService{
+		UnreadCountsFunc: func() (map[string]int, error) {
+			return map[string]int{"layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67": 2}, nil
+		},
+	}
+
+	var service glare.UserService = user
+	counts, err := service.UnreadCounts()
+	if err != nil || len(counts) != 1 || len(user.CallsTo("UnreadCounts")) != 1 {
+		t.Logf("Unexpected unread counts %v: %v\n", counts, err)
+		t.Fail()
+	}
+
+	var messages glare.ScopedMessageService = &ScopedMessageService{}
+	if _, err = messages.Get("layer:///messages/940de862-3c96-11e4-baad-164230d1df67"); !errors.Is(err, ErrNotMocked) {
+		t.Logf("Expected ErrNotMocked, got %v\n", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,1071 @@
+// Code generated by gen.go; DO NOT EDIT.
+
+package glaremock
+
+import (
+	"time"
+
+	"github.com/jtreleaven/glare"
+)
+
+// ConversationService is a mock glare.ConversationService. Each method records
+// its call and delegates to the matching Func field, or fails with ErrNotMocked
+// when the field is nil.
+type ConversationService struct {
+	GetConversationsByUserFunc func(userID string) ([]glare.Conversation, error)
+	GetConversationByUserFunc  func(userID string, conversationID glare.ConversationID) (glare.Conversation, error)
+	GetConversationByIDFunc    func(conversationID glare.ConversationID) (glare.Conversation, error)
+	CreateConversationFunc     func(pending glare.Conversation) (glare.Conversation, error)
+	EditConversationFunc       func(c glare.Conversation, changes []glare.EditRequest) (glare.Conversation, error)
+	DeleteConversationFunc     func(remove glare.Conversation) error
+
+	calls
+}
+
+// GetConversationsByUser implements glare.ConversationService.
+func (mock *ConversationService) GetConversationsByUser(userID string) ([]glare.Conversation, error) {
+	mock.record("GetConversationsByUser", userID)
+	if mock.GetConversationsByUserFunc == nil {
+		return nil, notMocked("GetConversationsByUser")
+	}
+
+	return mock.GetConversationsByUserFunc(userID)
+}
+
+// GetConversationByUser implements glare.ConversationService.
+func (mock *ConversationService) GetConversationByUser(userID string, conversationID glare.ConversationID) (glare.Conversation, error) {
+	mock.record("GetConversationByUser", userID, conversationID)
+	if mock.GetConversationByUserFunc == nil {
+		return glare.Conversation{}, notMocked("GetConversationByUser")
+	}
+
+	return mock.GetConversationByUserFunc(userID, conversationID)
+}
+
+// GetConversationByID implements glare.ConversationService.
+func (mock *ConversationService) GetConversationByID(conversationID glare.ConversationID) (glare.Conversation, error) {
+	mock.record("GetConversationByID", conversationID)
+	if mock.GetConversationByIDFunc == nil {
+		return glare.Conversation{}, notMocked("GetConversationByID")
+	}
+
+	return mock.GetConversationByIDFunc(conversationID)
+}
+
+// CreateConversation implements glare.ConversationService.
+func (mock *ConversationService) CreateConversation(pending glare.Conversation) (glare.Conversation, error) {
+	mock.record("CreateConversation", pending)
+	if mock.CreateConversationFunc == nil {
+		return glare.Conversation{}, notMocked("CreateConversation")
+	}
+
+	return mock.CreateConversationFunc(pending)
+}
+
+// EditConversation implements glare.ConversationService.
+func (mock *ConversationService) EditConversation(c glare.Conversation, changes []glare.EditRequest) (glare.Conversation, error) {
+	mock.record("EditConversation", c, changes)
+	if mock.EditConversationFunc == nil {
+		return glare.Conversation{}, notMocked("EditConversation")
+	}
+
+	return mock.EditConversationFunc(c, changes)
+}
+
+// DeleteConversation implements glare.ConversationService.
+func (mock *ConversationService) DeleteConversation(remove glare.Conversation) error {
+	mock.record("DeleteConversation", remove)
+	if mock.DeleteConversationFunc == nil {
+		return notMocked("DeleteConversation")
+	}
+
+	return mock.DeleteConversationFunc(remove)
+}
+
+// MessageService is a mock glare.MessageService. Each method records its call
+// and delegates to the matching Func field, or fails with ErrNotMocked when the
+// field is nil.
+type MessageService struct {
+	SendMessageFunc            func(m glare.Message, c glare.Conversation) (glare.Message, error)
+	GetMessageFunc             func(id glare.MessageID) (glare.Message, error)
+	GetMessageByUserFunc       func(userID string, id glare.MessageID) (glare.Message, error)
+	RetrieveMessagesFunc       func(c glare.Conversation, pageSize int, fromID glare.MessageID) ([]glare.Message, error)
+	RetrieveMessagesByUserFunc func(userID string, c glare.Conversation) ([]glare.Message, error)
+	DeleteMessageFunc          func(m glare.Message, c glare.Conversation) error
+	DeleteMessageWithModeFunc  func(m glare.Message, c glare.Conversation, mode string) error
+	DeleteMessageByUserFunc    func(userID string, m glare.Message, mode string) error
+	AddMessagePartFunc         func(m glare.Message, part glare.MessagePart) (glare.MessagePart, error)
+	UpdateMessagePartFunc      func(m glare.Message, part glare.MessagePart) error
+	DeleteMessagePartFunc      func(m glare.Message, part glare.MessagePart) error
+	MarkMessageReadFunc        func(userID string, m glare.Message) error
+	MarkMessageDeliveredFunc   func(userID string, m glare.Message) error
+	SendReceiptFunc            func(userID string, m glare.Message, receiptType string) error
+	MarkAllMessagesReadFunc    func(userID string, c glare.Conversation, position int64) error
+	GetUnreadMessageCountFunc  func(userID string, c glare.Conversation) (int, error)
+	GetUnreadMessageCountsFunc func(userID string) (map[string]int, error)
+
+	calls
+}
+
+// SendMessage implements glare.MessageService.
+func (mock *MessageService) SendMessage(m glare.Message, c glare.Conversation) (glare.Message, error) {
+	mock.record("SendMessage", m, c)
+	if mock.SendMessageFunc == nil {
+		return glare.Message{}, notMocked("SendMessage")
+	}
+
+	return mock.SendMessageFunc(m, c)
+}
+
+// GetMessage implements glare.MessageService.
+func (mock *MessageService) GetMessage(id glare.MessageID) (glare.Message, error) {
+	mock.record("GetMessage", id)
+	if mock.GetMessageFunc == nil {
+		return glare.Message{}, notMocked("GetMessage")
+	}
+
+	return mock.GetMessageFunc(id)
+}
+
+// GetMessageByUser implements glare.MessageService.
+func (mock *MessageService) GetMessageByUser(userID string, id glare.MessageID) (glare.Message, error) {
+	mock.record("GetMessageByUser", userID, id)
+	if mock.GetMessageByUserFunc == nil {
+		return glare.Message{}, notMocked("GetMessageByUser")
+	}
+
+	return mock.GetMessageByUserFunc(userID, id)
+}
+
+// RetrieveMessages implements glare.MessageService.
+func (mock *MessageService) RetrieveMessages(c glare.Conversation, pageSize int, fromID glare.MessageID) ([]glare.Message, error) {
+	mock.record("RetrieveMessages", c, pageSize, fromID)
+	if mock.RetrieveMessagesFunc == nil {
+		return nil, notMocked("RetrieveMessages")
+	}
+
+	return mock.RetrieveMessagesFunc(c, pageSize, fromID)
+}
+
+// RetrieveMessagesByUser implements glare.MessageService.
+func (mock *MessageService) RetrieveMessagesByUser(userID string, c glare.Conversation) ([]glare.Message, error) {
+	mock.record("RetrieveMessagesByUser", userID, c)
+	if mock.RetrieveMessagesByUserFunc == nil {
+		return nil, notMocked("RetrieveMessagesByUser")
+	}
+
+	return mock.RetrieveMessagesByUserFunc(userID, c)
+}
+
+// DeleteMessage implements glare.MessageService.
+func (mock *MessageService) DeleteMessage(m glare.Message, c glare.Conversation) error {
+	mock.record("DeleteMessage", m, c)
+	if mock.DeleteMessageFunc == nil {
+		return notMocked("DeleteMessage")
+	}
+
+	return mock.DeleteMessageFunc(m, c)
+}
+
+// DeleteMessageWithMode implements glare.MessageService.
+func (mock *MessageService) DeleteMessageWithMode(m glare.Message, c glare.Conversation, mode string) error {
+	mock.record("DeleteMessageWithMode", m, c, mode)
+	if mock.DeleteMessageWithModeFunc == nil {
+		return notMocked("DeleteMessageWithMode")
+	}
+
+	return mock.DeleteMessageWithModeFunc(m, c, mode)
+}
+
+// DeleteMessageByUser implements glare.MessageService.
+func (mock *MessageService) DeleteMessageByUser(userID string, m glare.Message, mode string) error {
+	mock.record("DeleteMessageByUser", userID, m, mode)
+	if mock.DeleteMessageByUserFunc == nil {
+		return notMocked("DeleteMessageByUser")
+	}
+
+	return mock.DeleteMessageByUserFunc(userID, m, mode)
+}
+
+// AddMessagePart implements glare.MessageService.
+func (mock *MessageService) AddMessagePart(m glare.Message, part glare.MessagePart) (glare.MessagePart, error) {
+	mock.record("AddMessagePart", m, part)
+	if mock.AddMessagePartFunc == nil {
+		return glare.MessagePart{}, notMocked("AddMessagePart")
+	}
+
+	return mock.AddMessagePartFunc(m, part)
+}
+
+// UpdateMessagePart implements glare.MessageService.
+func (mock *MessageService) UpdateMessagePart(m glare.Message, part glare.MessagePart) error {
+	mock.record("UpdateMessagePart", m, part)
+	if mock.UpdateMessagePartFunc == nil {
+		return notMocked("UpdateMessagePart")
+	}
+
+	return mock.UpdateMessagePartFunc(m, part)
+}
+
+// DeleteMessagePart implements glare.MessageService.
+func (mock *MessageService) DeleteMessagePart(m glare.Message, part glare.MessagePart) error {
+	mock.record("DeleteMessagePart", m, part)
+	if mock.DeleteMessagePartFunc == nil {
+		return notMocked("DeleteMessagePart")
+	}
+
+	return mock.DeleteMessagePartFunc(m, part)
+}
+
+// MarkMessageRead implements glare.MessageService.
+func (mock *MessageService) MarkMessageRead(userID string, m glare.Message) error {
+	mock.record("MarkMessageRead", userID, m)
+	if mock.MarkMessageReadFunc == nil {
+		return notMocked("MarkMessageRead")
+	}
+
+	return mock.MarkMessageReadFunc(userID, m)
+}
+
+// MarkMessageDelivered implements glare.MessageService.
+func (mock *MessageService) MarkMessageDelivered(userID string, m glare.Message) error {
+	mock.record("MarkMessageDelivered", userID, m)
+	if mock.MarkMessageDeliveredFunc == nil {
+		return notMocked("MarkMessageDelivered")
+	}
+
+	return mock.MarkMessageDeliveredFunc(userID, m)
+}
+
+// SendReceipt implements glare.MessageService.
+func (mock *MessageService) SendReceipt(userID string, m glare.Message, receiptType string) error {
+	mock.record("SendReceipt", userID, m, receiptType)
+	if mock.SendReceiptFunc == nil {
+		return notMocked("SendReceipt")
+	}
+
+	return mock.SendReceiptFunc(userID, m, receiptType)
+}
+
+// MarkAllMessagesRead implements glare.MessageService.
+func (mock *MessageService) MarkAllMessagesRead(userID string, c glare.Conversation, position int64) error {
+	mock.record("MarkAllMessagesRead", userID, c, position)
+	if mock.MarkAllMessagesReadFunc == nil {
+		return notMocked("MarkAllMessagesRead")
+	}
+
+	return mock.MarkAllMessagesReadFunc(userID, c, position)
+}
+
+// GetUnreadMessageCount implements glare.MessageService.
+func (mock *MessageService) GetUnreadMessageCount(userID string, c glare.Conversation) (int, error) {
+	mock.record("GetUnreadMessageCount", userID, c)
+	if mock.GetUnreadMessageCountFunc == nil {
+		return 0, notMocked("GetUnreadMessageCount")
+	}
+
+	return mock.GetUnreadMessageCountFunc(userID, c)
+}
+
+// GetUnreadMessageCounts implements glare.MessageService.
+func (mock *MessageService) GetUnreadMessageCounts(userID string) (map[string]int, error) {
+	mock.record("GetUnreadMessageCounts", userID)
+	if mock.GetUnreadMessageCountsFunc == nil {
+		return nil, notMocked("GetUnreadMessageCounts")
+	}
+
+	return mock.GetUnreadMessageCountsFunc(userID)
+}
+
+// IdentityService is a mock glare.IdentityService. Each method records its call
+// and delegates to the matching Func field, or fails with ErrNotMocked when the
+// field is nil.
+type IdentityService struct {
+	RegisterIdentityFunc       func(id string, i glare.Identity) error
+	UpdateIdentityFunc         func(id string, changes ...glare.EditRequest) (glare.Identity, error)
+	UpsertIdentityFunc         func(id string, i glare.Identity) (bool, error)
+	RetrieveIdentityFunc       func(id string) (glare.Identity, error)
+	DeleteIdentityFunc         func(id string) error
+	RetrieveBadgeFunc          func(id string) (glare.Badge, error)
+	SetBadgeFunc               func(id string, externalUnreadCount int) error
+	FollowIdentityFunc         func(id string, followID string) error
+	UnfollowIdentityFunc       func(id string, followID string) error
+	ListFollowedIdentitiesFunc func(id string) ([]string, error)
+	SetFollowedIdentitiesFunc  func(id string, followIDs []string) error
+	ListBlockedIdentitiesFunc  func(id string) ([]glare.Identity, error)
+	BlockIdentityFunc          func(id string, blockID string) error
+	UnblockIdentityFunc        func(id string, blockID string) error
+
+	calls
+}
+
+// RegisterIdentity implements glare.IdentityService.
+func (mock *IdentityService) RegisterIdentity(id string, i glare.Identity) error {
+	mock.record("RegisterIdentity", id, i)
+	if mock.RegisterIdentityFunc == nil {
+		return notMocked("RegisterIdentity")
+	}
+
+	return mock.RegisterIdentityFunc(id, i)
+}
+
+// UpdateIdentity implements glare.IdentityService.
+func (mock *IdentityService) UpdateIdentity(id string, changes ...glare.EditRequest) (glare.Identity, error) {
+	mock.record("UpdateIdentity", id, changes)
+	if mock.UpdateIdentityFunc == nil {
+		return glare.Identity{}, notMocked("UpdateIdentity")
+	}
+
+	return mock.UpdateIdentityFunc(id, changes...)
+}
+
+// UpsertIdentity implements glare.IdentityService.
+func (mock *IdentityService) UpsertIdentity(id string, i glare.Identity) (bool, error) {
+	mock.record("UpsertIdentity", id, i)
+	if mock.UpsertIdentityFunc == nil {
+		return false, notMocked("UpsertIdentity")
+	}
+
+	return mock.UpsertIdentityFunc(id, i)
+}
+
+// RetrieveIdentity implements glare.IdentityService.
+func (mock *IdentityService) RetrieveIdentity(id string) (glare.Identity, error) {
+	mock.record("RetrieveIdentity", id)
+	if mock.RetrieveIdentityFunc == nil {
+		return glare.Identity{}, notMocked("RetrieveIdentity")
+	}
+
+	return mock.RetrieveIdentityFunc(id)
+}
+
+// DeleteIdentity implements glare.IdentityService.
+func (mock *IdentityService) DeleteIdentity(id string) error {
+	mock.record("DeleteIdentity", id)
+	if mock.DeleteIdentityFunc == nil {
+		return notMocked("DeleteIdentity")
+	}
+
+	return mock.DeleteIdentityFunc(id)
+}
+
+// RetrieveBadge implements glare.IdentityService.
+func (mock *IdentityService) RetrieveBadge(id string) (glare.Badge, error) {
+	mock.record("RetrieveBadge", id)
+	if mock.RetrieveBadgeFunc == nil {
+		return glare.Badge{}, notMocked("RetrieveBadge")
+	}
+
+	return mock.RetrieveBadgeFunc(id)
+}
+
+// SetBadge implements glare.IdentityService.
+func (mock *IdentityService) SetBadge(id string, externalUnreadCount int) error {
+	mock.record("SetBadge", id, externalUnreadCount)
+	if mock.SetBadgeFunc == nil {
+		return notMocked("SetBadge")
+	}
+
+	return mock.SetBadgeFunc(id, externalUnreadCount)
+}
+
+// FollowIdentity implements glare.IdentityService.
+func (mock *IdentityService) FollowIdentity(id string, followID string) error {
+	mock.record("FollowIdentity", id, followID)
+	if mock.FollowIdentityFunc == nil {
+		return notMocked("FollowIdentity")
+	}
+
+	return mock.FollowIdentityFunc(id, followID)
+}
+
+// UnfollowIdentity implements glare.IdentityService.
+func (mock *IdentityService) UnfollowIdentity(id string, followID string) error {
+	mock.record("UnfollowIdentity", id, followID)
+	if mock.UnfollowIdentityFunc == nil {
+		return notMocked("UnfollowIdentity")
+	}
+
+	return mock.UnfollowIdentityFunc(id, followID)
+}
+
+// ListFollowedIdentities implements glare.IdentityService.
+func (mock *IdentityService) ListFollowedIdentities(id string) ([]string, error) {
+	mock.record("ListFollowedIdentities", id)
+	if mock.ListFollowedIdentitiesFunc == nil {
+		return nil, notMocked("ListFollowedIdentities")
+	}
+
+	return mock.ListFollowedIdentitiesFunc(id)
+}
+
+// SetFollowedIdentities implements glare.IdentityService.
+func (mock *IdentityService) SetFollowedIdentities(id string, followIDs []string) error {
+	mock.record("SetFollowedIdentities", id, followIDs)
+	if mock.SetFollowedIdentitiesFunc == nil {
+		return notMocked("SetFollowedIdentities")
+	}
+
+	return mock.SetFollowedIdentitiesFunc(id, followIDs)
+}
+
+// ListBlockedIdentities implements glare.IdentityService.
+func (mock *IdentityService) ListBlockedIdentities(id string) ([]glare.Identity, error) {
+	mock.record("ListBlockedIdentities", id)
+	if mock.ListBlockedIdentitiesFunc == nil {
+		return nil, notMocked("ListBlockedIdentities")
+	}
+
+	return mock.ListBlockedIdentitiesFunc(id)
+}
+
+// BlockIdentity implements glare.IdentityService.
+func (mock *IdentityService) BlockIdentity(id string, blockID string) error {
+	mock.record("BlockIdentity", id, blockID)
+	if mock.BlockIdentityFunc == nil {
+		return notMocked("BlockIdentity")
+	}
+
+	return mock.BlockIdentityFunc(id, blockID)
+}
+
+// UnblockIdentity implements glare.IdentityService.
+func (mock *IdentityService) UnblockIdentity(id string, blockID string) error {
+	mock.record("UnblockIdentity", id, blockID)
+	if mock.UnblockIdentityFunc == nil {
+		return notMocked("UnblockIdentity")
+	}
+
+	return mock.UnblockIdentityFunc(id, blockID)
+}
+
+// WebHookService is a mock glare.WebHookService. Each method records its call
+// and delegates to the matching Func field, or fails with ErrNotMocked when the
+// field is nil.
+type WebHookService struct {
+	RegisterWebHookFunc            func(created glare.WebHook) (glare.WebHook, error)
+	RegisterAndActivateWebHookFunc func(created glare.WebHook, responder *glare.WebHookChallengeResponder, timeout time.Duration) (glare.WebHook, error)
+	ListWebHooksFunc               func() ([]glare.WebHook, error)
+	GetWebHookFunc                 func(id string) (glare.WebHook, error)
+	ActivateWebHookFunc            func(w glare.WebHook) (glare.WebHook, error)
+	DeactivateWebHookFunc          func(w glare.WebHook) (glare.WebHook, error)
+	DeleteWebHookFunc              func(w glare.WebHook) error
+
+	calls
+}
+
+// RegisterWebHook implements glare.WebHookService.
+func (mock *WebHookService) RegisterWebHook(created glare.WebHook) (glare.WebHook, error) {
+	mock.record("RegisterWebHook", created)
+	if mock.RegisterWebHookFunc == nil {
+		return glare.WebHook{}, notMocked("RegisterWebHook")
+	}
+
+	return mock.RegisterWebHookFunc(created)
+}
+
+// RegisterAndActivateWebHook implements glare.WebHookService.
+func (mock *WebHookService) RegisterAndActivateWebHook(created glare.WebHook, responder *glare.WebHookChallengeResponder, timeout time.Duration) (glare.WebHook, error) {
+	mock.record("RegisterAndActivateWebHook", created, responder, timeout)
+	if mock.RegisterAndActivateWebHookFunc == nil {
+		return glare.WebHook{}, notMocked("RegisterAndActivateWebHook")
+	}
+
+	return mock.RegisterAndActivateWebHookFunc(created, responder, timeout)
+}
+
+// ListWebHooks implements glare.WebHookService.
+func (mock *WebHookService) ListWebHooks() ([]glare.WebHook, error) {
+	mock.record("ListWebHooks")
+	if mock.ListWebHooksFunc == nil {
+		return nil, notMocked("ListWebHooks")
+	}
+
+	return mock.ListWebHooksFunc()
+}
+
+// GetWebHook implements glare.WebHookService.
+func (mock *WebHookService) GetWebHook(id string) (glare.WebHook, error) {
+	mock.record("GetWebHook", id)
+	if mock.GetWebHookFunc == nil {
+		return glare.WebHook{}, notMocked("GetWebHook")
+	}
+
+	return mock.GetWebHookFunc(id)
+}
+
+// ActivateWebHook implements glare.WebHookService.
+func (mock *WebHookService) ActivateWebHook(w glare.WebHook) (glare.WebHook, error) {
+	mock.record("ActivateWebHook", w)
+	if mock.ActivateWebHookFunc == nil {
+		return glare.WebHook{}, notMocked("ActivateWebHook")
+	}
+
+	return mock.ActivateWebHookFunc(w)
+}
+
+// DeactivateWebHook implements glare.WebHookService.
+func (mock *WebHookService) DeactivateWebHook(w glare.WebHook) (glare.WebHook, error) {
+	mock.record("DeactivateWebHook", w)
+	if mock.DeactivateWebHookFunc == nil {
+		return glare.WebHook{}, notMocked("DeactivateWebHook")
+	}
+
+	return mock.DeactivateWebHookFunc(w)
+}
+
+// DeleteWebHook implements glare.WebHookService.
+func (mock *WebHookService) DeleteWebHook(w glare.WebHook) error {
+	mock.record("DeleteWebHook", w)
+	if mock.DeleteWebHookFunc == nil {
+		return notMocked("DeleteWebHook")
+	}
+
+	return mock.DeleteWebHookFunc(w)
+}
+
+// ScopedConversationService is a mock glare.ScopedConversationService. Each
+// method records its call and delegates to the matching Func field, or fails
+// with ErrNotMocked when the field is nil.
+type ScopedConversationService struct {
+	GetFunc    func(conversationID glare.ConversationID) (glare.Conversation, error)
+	CreateFunc func(pending glare.Conversation) (glare.Conversation, error)
+	EditFunc   func(c glare.Conversation, changes []glare.EditRequest) (glare.Conversation, error)
+	DeleteFunc func(remove glare.Conversation) error
+
+	calls
+}
+
+// Get implements glare.ScopedConversationService.
+func (mock *ScopedConversationService) Get(conversationID glare.ConversationID) (glare.Conversation, error) {
+	mock.record("Get", conversationID)
+	if mock.GetFunc == nil {
+		return glare.Conversation{}, notMocked("Get")
+	}
+
+	return mock.GetFunc(conversationID)
+}
+
+// Create implements glare.ScopedConversationService.
+func (mock *ScopedConversationService) Create(pending glare.Conversation) (glare.Conversation, error) {
+	mock.record("Create", pending)
+	if mock.CreateFunc == nil {
+		return glare.Conversation{}, notMocked("Create")
+	}
+
+	return mock.CreateFunc(pending)
+}
+
+// Edit implements glare.ScopedConversationService.
+func (mock *ScopedConversationService) Edit(c glare.Conversation, changes []glare.EditRequest) (glare.Conversation, error) {
+	mock.record("Edit", c, changes)
+	if mock.EditFunc == nil {
+		return glare.Conversation{}, notMocked("Edit")
+	}
+
+	return mock.EditFunc(c, changes)
+}
+
+// Delete implements glare.ScopedConversationService.
+func (mock *ScopedConversationService) Delete(remove glare.Conversation) error {
+	mock.record("Delete", remove)
+	if mock.DeleteFunc == nil {
+		return notMocked("Delete")
+	}
+
+	return mock.DeleteFunc(remove)
+}
+
+// ScopedMessageService is a mock glare.ScopedMessageService. Each method
+// records its call and delegates to the matching Func field, or fails with
+// ErrNotMocked when the field is nil.
+type ScopedMessageService struct {
+	SendFunc       func(m glare.Message, c glare.Conversation) (glare.Message, error)
+	GetFunc        func(id glare.MessageID) (glare.Message, error)
+	ListFunc       func(c glare.Conversation, pageSize int, fromID glare.MessageID) ([]glare.Message, error)
+	DeleteFunc     func(m glare.Message, c glare.Conversation, mode string) error
+	AddPartFunc    func(m glare.Message, part glare.MessagePart) (glare.MessagePart, error)
+	UpdatePartFunc func(m glare.Message, part glare.MessagePart) error
+	DeletePartFunc func(m glare.Message, part glare.MessagePart) error
+
+	calls
+}
+
+// Send implements glare.ScopedMessageService.
+func (mock *ScopedMessageService) Send(m glare.Message, c glare.Conversation) (glare.Message, error) {
+	mock.record("Send", m, c)
+	if mock.SendFunc == nil {
+		return glare.Message{}, notMocked("Send")
+	}
+
+	return mock.SendFunc(m, c)
+}
+
+// Get implements glare.ScopedMessageService.
+func (mock *ScopedMessageService) Get(id glare.MessageID) (glare.Message, error) {
+	mock.record("Get", id)
+	if mock.GetFunc == nil {
+		return glare.Message{}, notMocked("Get")
+	}
+
+	return mock.GetFunc(id)
+}
+
+// List implements glare.ScopedMessageService.
+func (mock *ScopedMessageService) List(c glare.Conversation, pageSize int, fromID glare.MessageID) ([]glare.Message, error) {
+	mock.record("List", c, pageSize, fromID)
+	if mock.ListFunc == nil {
+		return nil, notMocked("List")
+	}
+
+	return mock.ListFunc(c, pageSize, fromID)
+}
+
+// Delete implements glare.ScopedMessageService.
+func (mock *ScopedMessageService) Delete(m glare.Message, c glare.Conversation, mode string) error {
+	mock.record("Delete", m, c, mode)
+	if mock.DeleteFunc == nil {
+		return notMocked("Delete")
+	}
+
+	return mock.DeleteFunc(m, c, mode)
+}
+
+// AddPart implements glare.ScopedMessageService.
+func (mock *ScopedMessageService) AddPart(m glare.Message, part glare.MessagePart) (glare.MessagePart, error) {
+	mock.record("AddPart", m, part)
+	if mock.AddPartFunc == nil {
+		return glare.MessagePart{}, notMocked("AddPart")
+	}
+
+	return mock.AddPartFunc(m, part)
+}
+
+// UpdatePart implements glare.ScopedMessageService.
+func (mock *ScopedMessageService) UpdatePart(m glare.Message, part glare.MessagePart) error {
+	mock.record("UpdatePart", m, part)
+	if mock.UpdatePartFunc == nil {
+		return notMocked("UpdatePart")
+	}
+
+	return mock.UpdatePartFunc(m, part)
+}
+
+// DeletePart implements glare.ScopedMessageService.
+func (mock *ScopedMessageService) DeletePart(m glare.Message, part glare.MessagePart) error {
+	mock.record("DeletePart", m, part)
+	if mock.DeletePartFunc == nil {
+		return notMocked("DeletePart")
+	}
+
+	return mock.DeletePartFunc(m, part)
+}
+
+// ScopedIdentityService is a mock glare.ScopedIdentityService. Each method
+// records its call and delegates to the matching Func field, or fails with
+// ErrNotMocked when the field is nil.
+type ScopedIdentityService struct {
+	RegisterFunc func(id string, i glare.Identity) error
+	UpdateFunc   func(id string, changes ...glare.EditRequest) (glare.Identity, error)
+	UpsertFunc   func(id string, i glare.Identity) (bool, error)
+	GetFunc      func(id string) (glare.Identity, error)
+	DeleteFunc   func(id string) error
+
+	calls
+}
+
+// Register implements glare.ScopedIdentityService.
+func (mock *ScopedIdentityService) Register(id string, i glare.Identity) error {
+	mock.record("Register", id, i)
+	if mock.RegisterFunc == nil {
+		return notMocked("Register")
+	}
+
+	return mock.RegisterFunc(id, i)
+}
+
+// Update implements glare.ScopedIdentityService.
+func (mock *ScopedIdentityService) Update(id string, changes ...glare.EditRequest) (glare.Identity, error) {
+	mock.record("Update", id, changes)
+	if mock.UpdateFunc == nil {
+		return glare.Identity{}, notMocked("Update")
+	}
+
+	return mock.UpdateFunc(id, changes...)
+}
+
+// Upsert implements glare.ScopedIdentityService.
+func (mock *ScopedIdentityService) Upsert(id string, i glare.Identity) (bool, error) {
+	mock.record("Upsert", id, i)
+	if mock.UpsertFunc == nil {
+		return false, notMocked("Upsert")
+	}
+
+	return mock.UpsertFunc(id, i)
+}
+
+// Get implements glare.ScopedIdentityService.
+func (mock *ScopedIdentityService) Get(id string) (glare.Identity, error) {
+	mock.record("Get", id)
+	if mock.GetFunc == nil {
+		return glare.Identity{}, notMocked("Get")
+	}
+
+	return mock.GetFunc(id)
+}
+
+// Delete implements glare.ScopedIdentityService.
+func (mock *ScopedIdentityService) Delete(id string) error {
+	mock.record("Delete", id)
+	if mock.DeleteFunc == nil {
+		return notMocked("Delete")
+	}
+
+	return mock.DeleteFunc(id)
+}
+
+// ScopedWebHookService is a mock glare.ScopedWebHookService. Each method
+// records its call and delegates to the matching Func field, or fails with
+// ErrNotMocked when the field is nil.
+type ScopedWebHookService struct {
+	RegisterFunc            func(created glare.WebHook) (glare.WebHook, error)
+	RegisterAndActivateFunc func(created glare.WebHook, responder *glare.WebHookChallengeResponder, timeout time.Duration) (glare.WebHook, error)
+	ReconcileFunc           func(desired []glare.WebHook, opts glare.ReconcileOptions) (glare.WebHookPlan, error)
+	ListFunc                func() ([]glare.WebHook, error)
+	GetFunc                 func(id string) (glare.WebHook, error)
+	ActivateFunc            func(w glare.WebHook) (glare.WebHook, error)
+	DeactivateFunc          func(w glare.WebHook) (glare.WebHook, error)
+	DeleteFunc              func(w glare.WebHook) error
+
+	calls
+}
+
+// Register implements glare.ScopedWebHookService.
+func (mock *ScopedWebHookService) Register(created glare.WebHook) (glare.WebHook, error) {
+	mock.record("Register", created)
+	if mock.RegisterFunc == nil {
+		return glare.WebHook{}, notMocked("Register")
+	}
+
+	return mock.RegisterFunc(created)
+}
+
+// RegisterAndActivate implements glare.ScopedWebHookService.
+func (mock *ScopedWebHookService) RegisterAndActivate(created glare.WebHook, responder *glare.WebHookChallengeResponder, timeout time.Duration) (glare.WebHook, error) {
+	mock.record("RegisterAndActivate", created, responder, timeout)
+	if mock.RegisterAndActivateFunc == nil {
+		return glare.WebHook{}, notMocked("RegisterAndActivate")
+	}
+
+	return mock.RegisterAndActivateFunc(created, responder, timeout)
+}
+
+// Reconcile implements glare.ScopedWebHookService.
+func (mock *ScopedWebHookService) Reconcile(desired []glare.WebHook, opts glare.ReconcileOptions) (glare.WebHookPlan, error) {
+	mock.record("Reconcile", desired, opts)
+	if mock.ReconcileFunc == nil {
+		return glare.WebHookPlan{}, notMocked("Reconcile")
+	}
+
+	return mock.ReconcileFunc(desired, opts)
+}
+
+// List implements glare.ScopedWebHookService.
+func (mock *ScopedWebHookService) List() ([]glare.WebHook, error) {
+	mock.record("List")
+	if mock.ListFunc == nil {
+		return nil, notMocked("List")
+	}
+
+	return mock.ListFunc()
+}
+
+// Get implements glare.ScopedWebHookService.
+func (mock *ScopedWebHookService) Get(id string) (glare.WebHook, error) {
+	mock.record("Get", id)
+	if mock.GetFunc == nil {
+		return glare.WebHook{}, notMocked("Get")
+	}
+
+	return mock.GetFunc(id)
+}
+
+// Activate implements glare.ScopedWebHookService.
+func (mock *ScopedWebHookService) Activate(w glare.WebHook) (glare.WebHook, error) {
+	mock.record("Activate", w)
+	if mock.ActivateFunc == nil {
+		return glare.WebHook{}, notMocked("Activate")
+	}
+
+	return mock.ActivateFunc(w)
+}
+
+// Deactivate implements glare.ScopedWebHookService.
+func (mock *ScopedWebHookService) Deactivate(w glare.WebHook) (glare.WebHook, error) {
+	mock.record("Deactivate", w)
+	if mock.DeactivateFunc == nil {
+		return glare.WebHook{}, notMocked("Deactivate")
+	}
+
+	return mock.DeactivateFunc(w)
+}
+
+// Delete implements glare.ScopedWebHookService.
+func (mock *ScopedWebHookService) Delete(w glare.WebHook) error {
+	mock.record("Delete", w)
+	if mock.DeleteFunc == nil {
+		return notMocked("Delete")
+	}
+
+	return mock.DeleteFunc(w)
+}
+
+// UserService is a mock glare.UserService. Each method records its call and
+// delegates to the matching Func field, or fails with ErrNotMocked when the
+// field is nil.
+type UserService struct {
+	UserIDFunc        func() string
+	IdentityFunc      func() (glare.Identity, error)
+	ConversationsFunc func() ([]glare.Conversation, error)
+	ConversationFunc  func(conversationID glare.ConversationID) (glare.Conversation, error)
+	MessageFunc       func(id glare.MessageID) (glare.Message, error)
+	MessagesFunc      func(c glare.Conversation) ([]glare.Message, error)
+	DeleteMessageFunc func(m glare.Message, mode string) error
+	MarkReadFunc      func(m glare.Message) error
+	MarkDeliveredFunc func(m glare.Message) error
+	SendReceiptFunc   func(m glare.Message, receiptType string) error
+	MarkAllReadFunc   func(c glare.Conversation, position int64) error
+	UnreadCountFunc   func(c glare.Conversation) (int, error)
+	UnreadCountsFunc  func() (map[string]int, error)
+	BadgeFunc         func() (glare.Badge, error)
+	SetBadgeFunc      func(externalUnreadCount int) error
+	FollowFunc        func(followID string) error
+	UnfollowFunc      func(followID string) error
+	FollowingFunc     func() ([]string, error)
+	SetFollowingFunc  func(followIDs []string) error
+	BlockedFunc       func() ([]glare.Identity, error)
+	BlockFunc         func(blockID string) error
+	UnblockFunc       func(blockID string) error
+
+	calls
+}
+
+// UserID implements glare.UserService.
+func (mock *UserService) UserID() string {
+	mock.record("UserID")
+	if mock.UserIDFunc == nil {
+		return ""
+	}
+
+	return mock.UserIDFunc()
+}
+
+// Identity implements glare.UserService.
+func (mock *UserService) Identity() (glare.Identity, error) {
+	mock.record("Identity")
+	if mock.IdentityFunc == nil {
+		return glare.Identity{}, notMocked("Identity")
+	}
+
+	return mock.IdentityFunc()
+}
+
+// Conversations implements glare.UserService.
+func (mock *UserService) Conversations() ([]glare.Conversation, error) {
+	mock.record("Conversations")
+	if mock.ConversationsFunc == nil {
+		return nil, notMocked("Conversations")
+	}
+
+	return mock.ConversationsFunc()
+}
+
+// Conversation implements glare.UserService.
+func (mock *UserService) Conversation(conversationID glare.ConversationID) (glare.Conversation, error) {
+	mock.record("Conversation", conversationID)
+	if mock.ConversationFunc == nil {
+		return glare.Conversation{}, notMocked("Conversation")
+	}
+
+	return mock.ConversationFunc(conversationID)
+}
+
+// Message implements glare.UserService.
+func (mock *UserService) Message(id glare.MessageID) (glare.Message, error) {
+	mock.record("Message", id)
+	if mock.MessageFunc == nil {
+		return glare.Message{}, notMocked("Message")
+	}
+
+	return mock.MessageFunc(id)
+}
+
+// Messages implements glare.UserService.
+func (mock *UserService) Messages(c glare.Conversation) ([]glare.Message, error) {
+	mock.record("Messages", c)
+	if mock.MessagesFunc == nil {
+		return nil, notMocked("Messages")
+	}
+
+	return mock.MessagesFunc(c)
+}
+
+// DeleteMessage implements glare.UserService.
+func (mock *UserService) DeleteMessage(m glare.Message, mode string) error {
+	mock.record("DeleteMessage", m, mode)
+	if mock.DeleteMessageFunc == nil {
+		return notMocked("DeleteMessage")
+	}
+
+	return mock.DeleteMessageFunc(m, mode)
+}
+
+// MarkRead implements glare.UserService.
+func (mock *UserService) MarkRead(m glare.Message) error {
+	mock.record("MarkRead", m)
+	if mock.MarkReadFunc == nil {
+		return notMocked("MarkRead")
+	}
+
+	return mock.MarkReadFunc(m)
+}
+
+// MarkDelivered implements glare.UserService.
+func (mock *UserService) MarkDelivered(m glare.Message) error {
+	mock.record("MarkDelivered", m)
+	if mock.MarkDeliveredFunc == nil {
+		return notMocked("MarkDelivered")
+	}
+
+	return mock.MarkDeliveredFunc(m)
+}
+
+// SendReceipt implements glare.UserService.
+func (mock *UserService) SendReceipt(m glare.Message, receiptType string) error {
+	mock.record("SendReceipt", m, receiptType)
+	if mock.SendReceiptFunc == nil {
+		return notMocked("SendReceipt")
+	}
+
+	return mock.SendReceiptFunc(m, receiptType)
+}
+
+// MarkAllRead implements glare.UserService.
+func (mock *UserService) MarkAllRead(c glare.Conversation, position int64) error {
+	mock.record("MarkAllRead", c, position)
+	if mock.MarkAllReadFunc == nil {
+		return notMocked("MarkAllRead")
+	}
+
+	return mock.MarkAllReadFunc(c, position)
+}
+
+// UnreadCount implements glare.UserService.
+func (mock *UserService) UnreadCount(c glare.Conversation) (int, error) {
+	mock.record("UnreadCount", c)
+	if mock.UnreadCountFunc == nil {
+		return 0, notMocked("UnreadCount")
+	}
+
+	return mock.UnreadCountFunc(c)
+}
+
+// UnreadCounts implements glare.UserService.
+func (mock *UserService) UnreadCounts() (map[string]int, error) {
+	mock.record("UnreadCounts")
+	if mock.UnreadCountsFunc == nil {
+		return nil, notMocked("UnreadCounts")
+	}
+
+	return mock.UnreadCountsFunc()
+}
+
+// Badge implements glare.UserService.
+func (mock *UserService) Badge() (glare.Badge, error) {
+	mock.record("Badge")
+	if mock.BadgeFunc == nil {
+		return glare.Badge{}, notMocked("Badge")
+	}
+
+	return mock.BadgeFunc()
+}
+
+// SetBadge implements glare.UserService.
+func (mock *UserService) SetBadge(externalUnreadCount int) error {
+	mock.record("SetBadge", externalUnreadCount)
+	if mock.SetBadgeFunc == nil {
+		return notMocked("SetBadge")
+	}
+
+	return mock.SetBadgeFunc(externalUnreadCount)
+}
+
+// Follow implements glare.UserService.
+func (mock *UserService) Follow(followID string) error {
+	mock.record("Follow", followID)
+	if mock.FollowFunc == nil {
+		return notMocked("Follow")
+	}
+
+	return mock.FollowFunc(followID)
+}
+
+// Unfollow implements glare.UserService.
+func (mock *UserService) Unfollow(followID string) error {
+	mock.record("Unfollow", followID)
+	if mock.UnfollowFunc == nil {
+		return notMocked("Unfollow")
+	}
+
+	return mock.UnfollowFunc(followID)
+}
+
+// Following implements glare.UserService.
+func (mock *UserService) Following() ([]string, error) {
+	mock.record("Following")
+	if mock.FollowingFunc == nil {
+		return nil, notMocked("Following")
+	}
+
+	return mock.FollowingFunc()
+}
+
+// SetFollowing implements glare.UserService.
+func (mock *UserService) SetFollowing(followIDs []string) error {
+	mock.record("SetFollowing", followIDs)
+	if mock.SetFollowingFunc == nil {
+		return notMocked("SetFollowing")
+	}
+
+	return mock.SetFollowingFunc(followIDs)
+}
+
+// Blocked implements glare.UserService.
+func (mock *UserService) Blocked() ([]glare.Identity, error) {
+	mock.record("Blocked")
+	if mock.BlockedFunc == nil {
+		return nil, notMocked("Blocked")
+	}
+
+	return mock.BlockedFunc()
+}
+
+// Block implements glare.UserService.
+func (mock *UserService) Block(blockID string) error {
+	mock.record("Block", blockID)
+	if mock.BlockFunc == nil {
+		return notMocked("Block")
+	}
+
+	return mock.BlockFunc(blockID)
+}
+
+// Unblock implements glare.UserService.
+func (mock *UserService) Unblock(blockID string) error {
+	mock.record("Unblock", blockID)
+	if mock.UnblockFunc == nil {
+		return notMocked("Unblock")
+	}
+
+	return mock.UnblockFunc(blockID)
+}
@@ -0,0 +1,420 @@
+package glaretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces every scrubbed value in a cassette.
+const Redacted = "REDACTED"
+
+// DefaultScrubbedFields are the JSON fields whose values a Recorder redacts:
+// credentials, personal information about users, user IDs and the content of
+// messages and conversations. Every string and object key nested in them is
+// redacted.
+var DefaultScrubbedFields = []string{
+	"secret",
+	"token",
+	"session_token",
+	"identity_token",
+	"nonce",
+	"public_key",
+	"display_name",
+	"first_name",
+	"last_name",
+	"email_address",
+	"phone_number",
+	"avatar_url",
+	"user_id",
+	"participants",
+	"recipient_status",
+	"body",
+	"metadata",
+}
+
+// userPathSegments are the URL path segments followed by a user ID, which a
+// Recorder redacts.
+var userPathSegments = []string{"users", "blocks", "following"}
+
+// scrubbedHeaders are the headers a Recorder redacts.
+var scrubbedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Layer-Webhook-Signature"}
+
+// Cassette is a sequence of recorded Layer API interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the sanitized request of an Interaction.
+type RecordedRequest struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	Text   string            `json:"text,omitempty"`
+}
+
+// RecordedResponse is the sanitized response of an Interaction. JSON bodies are
+// kept in Body so that cassettes stay readable; anything else is kept in Text.
+type RecordedResponse struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+	Text       string            `json:"text,omitempty"`
+}
+
+// LoadCassette reads a cassette file.
+func LoadCassette(path string) (*Cassette, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err = json.Unmarshal(buf, &c); err != nil {
+		return nil, fmt.Errorf("Unable to read cassette %s: %s", path, err)
+	}
+
+	return &c, nil
+}
+
+// Save writes the cassette to path, creating its directory if needed.
+func (c *Cassette) Save(path string) error {
+	buf, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// Recorder is an http.RoundTripper that sends requests to Next and records a
+// sanitized copy of every exchange. Header credentials, the user IDs in URL
+// paths and everything nested in the Fields are replaced with Redacted, as is
+// every occurrence of the Scrub strings, such as the app ID, anywhere in the
+// exchange. It is safe for concurrent use.
+type Recorder struct {
+	// Next sends the requests. Defaults to http.DefaultTransport.
+	Next   http.RoundTripper
+	Fields []string
+	Scrub  []string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder returns a recorder that scrubs the DefaultScrubbedFields and
+// the given strings.
+func NewRecorder(next http.RoundTripper, scrub ...string) *Recorder {
+	return &Recorder{Next: next, Fields: DefaultScrubbedFields, Scrub: scrub}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    r.scrubURL(req.URL),
+			Header: r.scrubHeader(req.Header),
+		},
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     r.scrubHeader(res.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.Text = r.scrubBody(reqBody)
+	interaction.Response.Body, interaction.Response.Text = r.scrubBody(resBody)
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+// Cassette returns a copy of everything recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Save writes everything recorded so far to path.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+// scrubURL returns the sanitized URL, with the user IDs of its path redacted.
+func (r *Recorder) scrubURL(u *url.URL) string {
+	scrubbed := *u
+	segments := strings.Split(u.Path, "/")
+	for i := 1; i < len(segments); i++ {
+		for _, segment := range userPathSegments {
+			if segments[i-1] == segment && len(segments[i]) > 0 {
+				segments[i] = Redacted
+			}
+		}
+	}
+	scrubbed.Path = strings.Join(segments, "/")
+	scrubbed.RawPath = ""
+
+	return r.scrubString(scrubbed.String())
+}
+
+func (r *Recorder) scrubHeader(header http.Header) map[string]string {
+	scrubbed := make(map[string]string)
+	for name := range header {
+		value := r.scrubString(header.Get(name))
+		for _, h := range scrubbedHeaders {
+			if strings.EqualFold(name, h) {
+				value = Redacted
+			}
+		}
+		scrubbed[name] = value
+	}
+
+	return scrubbed
+}
+
+// scrubBody returns the sanitized body, as JSON when it is valid JSON or as
+// text otherwise.
+func (r *Recorder) scrubBody(body []byte) (json.RawMessage, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, r.scrubString(string(body))
+	}
+
+	scrubbed, err := json.Marshal(r.scrubValue(value, false))
+	if err != nil {
+		return nil, r.scrubString(string(body))
+	}
+
+	return scrubbed, ""
+}
+
+// scrubValue returns the sanitized JSON value. Every string and object key of
+// a redacted value, or of one of the Fields, is replaced; the keys of an
+// object are numbered so that they stay distinct.
+func (r *Recorder) scrubValue(value interface{}, redact bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		scrubbed := make(map[string]interface{}, len(v))
+		for n, k := range keys {
+			key := r.scrubString(k)
+			if redact {
+				key = fmt.Sprintf("%s-%d", Redacted, n+1)
+			}
+			scrubbed[key] = r.scrubValue(v[k], redact || r.scrubbedField(k))
+		}
+		return scrubbed
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.scrubValue(item, redact)
+		}
+		return v
+	case string:
+		if redact && len(v) > 0 {
+			return Redacted
+		}
+		return r.scrubString(v)
+	}
+
+	return value
+}
+
+// scrubbedField reports whether the values of the JSON field are redacted.
+func (r *Recorder) scrubbedField(key string) bool {
+	for _, field := range r.Fields {
+		if key == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Recorder) scrubString(s string) string {
+	for _, scrub := range r.Scrub {
+		if len(scrub) > 0 {
+			s = strings.Replace(s, scrub, Redacted, -1)
+		}
+	}
+
+	return s
+}
+
+// Replayer is an http.RoundTripper that answers requests from a cassette
+// instead of the network. Each interaction is replayed once, to the first
+// request with the same method, path and query; a Redacted path segment or
+// query value in the cassette matches anything. Requests without a matching
+// interaction fail. It is safe for concurrent use.
+type Replayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayer returns a replayer for the cassette.
+func NewReplayer(c *Cassette) *Replayer {
+	return &Replayer{cassette: c, used: make([]bool, len(c.Interactions))}
+}
+
+// Client returns an http.Client using the replayer, suitable for
+// glare.Backoff.Client.
+func (r *Replayer) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Remaining returns how many interactions have not been replayed yet.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var remaining int
+	for _, used := range r.used {
+		if !used {
+			remaining++
+		}
+	}
+
+	return remaining
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || !matchURL(interaction.Request.URL, req.URL) {
+			continue
+		}
+		r.used[i] = true
+
+		recorded := interaction.Response
+		body := []byte(recorded.Text)
+		if len(recorded.Body) > 0 {
+			body = recorded.Body
+		}
+
+		header := make(http.Header)
+		for name, value := range recorded.Header {
+			header.Set(name, value)
+		}
+		header.Del("Content-Length")
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("No recorded interaction for %s %s", req.Method, req.URL)
+}
+
+// matchURL compares the path and query of a recorded URL with a request URL,
+// treating Redacted segments and values as wildcards.
+func matchURL(recorded string, actual *url.URL) bool {
+	u, err := url.Parse(recorded)
+	if err != nil {
+		return false
+	}
+
+	recordedPath := strings.Split(strings.Trim(u.Path, "/"), "/")
+	actualPath := strings.Split(strings.Trim(actual.Path, "/"), "/")
+	if len(recordedPath) != len(actualPath) {
+		return false
+	}
+	for i := range recordedPath {
+		if recordedPath[i] != Redacted && recordedPath[i] != actualPath[i] {
+			return false
+		}
+	}
+
+	recordedQuery, actualQuery := u.Query(), actual.Query()
+	if len(recordedQuery) != len(actualQuery) {
+		return false
+	}
+	for key, values := range recordedQuery {
+		sort.Strings(values)
+		actualValues := actualQuery[key]
+		sort.Strings(actualValues)
+		if len(values) != len(actualValues) {
+			return false
+		}
+		for i := range values {
+			if values[i] != Redacted && values[i] != actualValues[i] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+package glaretest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtreleaven/glare"
+)
+
+// TestRecorderScrubsAndReplays should record sanitized cassettes that can be
+// replayed without the server.
+func TestRecorderScrubsAndReplays(t *testing.T) {
+	s := NewServer("secret-app", "token")
+	l := s.Layer()
+	recorder := NewRecorder(nil, "secret-app")
+	l.Backoff.Client = &http.Client{Transport: recorder}
+
+	if err := l.RegisterIdentity("u1", glare.Identity{DisplayName: "Jane Doe", Email: "jane@example.com"}); err != nil {
+		t.Fatalf("Unable to register identity: %s\n", err)
+	}
+	if _, err := l.RetrieveIdentity("u1"); err != nil {
+		t.Fatalf("Unable to retrieve identity: %s\n", err)
+	}
+	c, err := l.CreateConversation(glare.Conversation{
+		Participants: []string{"frodo-baggins", "samwise-gamgee"},
+		MetaData:     map[string]interface{}{"topic": "second breakfast"},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create conversation: %s\n", err)
+	}
+	m := glare.Message{Parts: []glare.MessagePart{{Body: "one ring to rule them all", MimeType: "text/plain"}}}
+	if _, err = l.SendMessage(m, c); err != nil {
+		t.Fatalf("Unable to send message: %s\n", err)
+	}
+	if _, err = l.RetrieveIdentity("frodo-baggins"); err == nil {
+		t.Fatalf("Expected an unknown identity\n")
+	}
+	s.Close()
+
+	path := filepath.Join(t.TempDir(), "identity.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Unable to save cassette: %s\n", err)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read cassette: %s\n", err)
+	}
+	leaks := []string{
+		"Bearer token", "Jane Doe", "jane@example.com", "secret-app",
+		"frodo-baggins", "samwise-gamgee", "second breakfast", "topic", "one ring",
+	}
+	for _, leaked := range leaks {
+		if strings.Contains(string(buf), leaked) {
+			t.Logf("Cassette leaks %q\n", leaked)
+			t.Fail()
+		}
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+	replayer := NewReplayer(cassette)
+	l.Backoff.Client = replayer.Client()
+
+	i, err := l.RetrieveIdentity("u1")
+	if err != nil || i.UserID != Redacted || i.DisplayName != Redacted {
+		t.Logf("Unexpected replayed identity %+v: %v\n", i, err)
+		t.Fail()
+	}
+
+	if replayer.Remaining() != 4 {
+		t.Logf("Expected the other interactions to remain unplayed, %d remaining\n", replayer.Remaining())
+		t.Fail()
+	}
+}
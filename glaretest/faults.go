@@ -0,0 +1,239 @@
+package glaretest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Fault describes how a FaultTransport misbehaves for a matching request.
+// Latency is applied first; the request then fails with a connection reset,
+// gets a synthesized StatusCode response, or is sent on with its response body
+// truncated.
+type Fault struct {
+	// Latency delays the request, honouring the request's context.
+	Latency time.Duration
+	// StatusCode answers the request without sending it. Body defaults to a
+	// Layer style JSON error.
+	StatusCode int
+	Body       string
+	// RetryAfter sets the Retry-After header, in whole seconds, of a
+	// synthesized response.
+	RetryAfter time.Duration
+	// Reset fails the request as if the connection was reset by the peer.
+	Reset bool
+	// Truncate cuts the real response body in half, leaving invalid JSON.
+	Truncate bool
+}
+
+// FaultRule injects a Fault into the requests matching Method and Route.
+// Route is a path.Match pattern for the URL path, such as
+// "/apps/*/conversations/*/messages"; empty values match every request.
+type FaultRule struct {
+	Method string
+	Route  string
+	// Nth is the 1-based matching call the fault starts at; zero means the
+	// first one.
+	Nth int
+	// Times is how many consecutive matching calls get the fault, so a 5xx
+	// burst of three is Times: 3. Zero means every call from Nth on.
+	Times int
+	Fault Fault
+
+	calls int
+}
+
+// FaultTransport is an http.RoundTripper for tests that injects faults into the
+// requests matching its rules and sends everything else to Next. Only the first
+// matching rule whose Nth and Times window covers the call applies to a
+// request. It is safe for concurrent use.
+type FaultTransport struct {
+	// Next sends requests that are not answered by a fault. Defaults to
+	// http.DefaultTransport.
+	Next http.RoundTripper
+
+	mu       sync.Mutex
+	rules    []*FaultRule
+	requests int
+	injected int
+}
+
+// NewFaultTransport returns a transport that injects the given rules, in order,
+// into requests sent through next.
+func NewFaultTransport(next http.RoundTripper, rules ...FaultRule) *FaultTransport {
+	t := &FaultTransport{Next: next}
+	for _, rule := range rules {
+		t.Add(rule)
+	}
+
+	return t
+}
+
+// Add appends a rule. Its call count starts from the moment it is added.
+func (t *FaultTransport) Add(rule FaultRule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rule.calls = 0
+	t.rules = append(t.rules, &rule)
+}
+
+// Reset removes every rule and clears the counters.
+func (t *FaultTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rules = nil
+	t.requests = 0
+	t.injected = 0
+}
+
+// Requests returns how many requests went through the transport.
+func (t *FaultTransport) Requests() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.requests
+}
+
+// Injected returns how many requests a fault was injected into.
+func (t *FaultTransport) Injected() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.injected
+}
+
+// Client returns an http.Client using the transport, suitable for
+// glare.Backoff.Client.
+func (t *FaultTransport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault, ok := t.match(req)
+	if !ok {
+		return t.next().RoundTrip(req)
+	}
+
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	switch {
+	case fault.Reset:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	case fault.StatusCode > 0:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return faultResponse(req, fault), nil
+	case fault.Truncate:
+		res, err := t.next().RoundTrip(req)
+		if err != nil {
+			return res, err
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = body[:len(body)/2]
+		res.Body = ioutil.NopCloser(bytes.NewReader(body))
+		res.ContentLength = int64(len(body))
+		res.Header.Del("Content-Length")
+		return res, nil
+	}
+
+	// A latency only fault lets the request through once the delay is over.
+	return t.next().RoundTrip(req)
+}
+
+// match counts the request against the rules and returns the fault to inject,
+// if any.
+func (t *FaultTransport) match(req *http.Request) (Fault, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var fault Fault
+	var injected bool
+	t.requests++
+	for _, rule := range t.rules {
+		if len(rule.Method) > 0 && rule.Method != req.Method {
+			continue
+		}
+		if len(rule.Route) > 0 {
+			if matched, _ := path.Match(rule.Route, req.URL.Path); !matched {
+				continue
+			}
+		}
+
+		// Every matching rule counts the call so that its window does not
+		// depend on the rules before it.
+		rule.calls++
+		start := rule.Nth
+		if start < 1 {
+			start = 1
+		}
+		if injected || rule.calls < start || (rule.Times > 0 && rule.calls >= start+rule.Times) {
+			continue
+		}
+
+		injected = true
+		fault = rule.Fault
+	}
+
+	if injected {
+		t.injected++
+	}
+
+	return fault, injected
+}
+
+func (t *FaultTransport) next() http.RoundTripper {
+	if t.Next != nil {
+		return t.Next
+	}
+
+	return http.DefaultTransport
+}
+
+func faultResponse(req *http.Request, fault Fault) *http.Response {
+	body := fault.Body
+	if len(body) == 0 {
+		body = fmt.Sprintf(`{"id":"injected_fault","code":%d,"message":"%s"}`, fault.StatusCode, http.StatusText(fault.StatusCode))
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	if fault.RetryAfter > 0 {
+		header.Set("Retry-After", strconv.Itoa(int(fault.RetryAfter/time.Second)))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fault.StatusCode, http.StatusText(fault.StatusCode)),
+		StatusCode:    fault.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
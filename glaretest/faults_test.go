@@ -0,0 +1,128 @@
+package glaretest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtreleaven/glare"
+)
+
+// faultyLayer returns a client of the server that retries tries times through
+// the fault transport.
+func faultyLayer(s *Server, faults *FaultTransport, tries int) glare.Layer {
+	l := s.Layer()
+	l.Backoff = glare.NewBackoff(tries, 1, 5, nil)
+	l.Backoff.Client = faults.Client()
+	return l
+}
+
+// TestFaultTransportServerErrorBurst should let Backoff.Do retry through a
+// burst of 5xx responses.
+func TestFaultTransportServerErrorBurst(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	faults := NewFaultTransport(nil, FaultRule{
+		Method: "POST",
+		Route:  "/apps/*/conversations",
+		Times:  2,
+		Fault:  Fault{StatusCode: 503},
+	})
+	l := faultyLayer(s, faults, 2)
+
+	if _, err := l.CreateConversation(glare.Conversation{Participants: []string{"a"}}); err != nil {
+		t.Logf("Expected the create to succeed after retrying: %s\n", err)
+		t.Fail()
+	}
+
+	if faults.Requests() != 3 || faults.Injected() != 2 {
+		t.Logf("Expected 3 requests with 2 faults, got %d with %d\n", faults.Requests(), faults.Injected())
+		t.Fail()
+	}
+}
+
+// TestFaultTransportNthCallReset should only reset the connection of the Nth
+// matching call.
+func TestFaultTransportNthCallReset(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	faults := NewFaultTransport(nil)
+	l := faultyLayer(s, faults, 0)
+
+	c, err := l.CreateConversation(glare.Conversation{Participants: []string{"a"}})
+	if err != nil {
+		t.Fatalf("Unable to create conversation: %s\n", err)
+	}
+
+	faults.Add(FaultRule{Method: "GET", Route: "/apps/*/conversations/*", Nth: 2, Times: 1, Fault: Fault{Reset: true}})
+	for i, fails := range []bool{false, true, false} {
+		if _, err = l.GetConversationByID(c.ID); (err != nil) != fails {
+			t.Logf("Call %d: expected failure %t, got %v\n", i+1, fails, err)
+			t.Fail()
+		}
+	}
+}
+
+// TestFaultTransportRetryAfter should make Backoff.Do wait for the Retry-After
+// of a rate limited response.
+func TestFaultTransportRetryAfter(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	faults := NewFaultTransport(nil, FaultRule{Times: 1, Fault: Fault{StatusCode: 429, RetryAfter: time.Second}})
+	l := faultyLayer(s, faults, 1)
+	l.Backoff.MaxTime = 2000
+
+	start := time.Now()
+	if _, err := l.CreateConversation(glare.Conversation{Participants: []string{"a"}}); err != nil {
+		t.Logf("Expected the create to succeed after being rate limited: %s\n", err)
+		t.Fail()
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Logf("Expected to wait for Retry-After, retried after %s\n", elapsed)
+		t.Fail()
+	}
+}
+
+// TestFaultTransportTruncatedJSON should surface a truncated body as a decode
+// error and add latency to the request.
+func TestFaultTransportTruncatedJSON(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	faults := NewFaultTransport(nil)
+	l := faultyLayer(s, faults, 0)
+
+	if err := l.RegisterIdentity("u1", glare.Identity{DisplayName: "One"}); err != nil {
+		t.Fatalf("Unable to register identity: %s\n", err)
+	}
+
+	faults.Add(FaultRule{Route: "/apps/*/users/*/identity", Fault: Fault{Truncate: true, Latency: 20 * time.Millisecond}})
+	start := time.Now()
+	if _, err := l.RetrieveIdentity("u1"); err == nil {
+		t.Log("Expected a truncated identity to fail to decode")
+		t.Fail()
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Logf("Expected latency to be injected, request took %s\n", elapsed)
+		t.Fail()
+	}
+}
+
+// TestFaultTransportRulesOnSameRoute should let a later rule on a route fire
+// once an earlier rule on the same route is out of its window.
+func TestFaultTransportRulesOnSameRoute(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	faults := NewFaultTransport(nil,
+		FaultRule{Method: "GET", Route: "/apps/*/webhooks", Times: 1, Fault: Fault{StatusCode: 500}},
+		FaultRule{Method: "GET", Route: "/apps/*/webhooks", Nth: 3, Times: 1, Fault: Fault{StatusCode: 503}},
+	)
+	l := faultyLayer(s, faults, 0)
+
+	for i, fails := range []bool{true, false, true, false} {
+		if _, err := l.ListWebHooks(); (err != nil) != fails {
+			t.Logf("Call %d: expected failure %t, got %v\n", i+1, fails, err)
+			t.Fail()
+		}
+	}
+}
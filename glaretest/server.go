@@ -0,0 +1,815 @@
+// Package glaretest provides an in-process fake of the Layer Platform API for
+// integration testing code built on glare.
+package glaretest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jtreleaven/glare"
+)
+
+// Server is a stateful fake of the Layer Platform API backed by an
+// httptest.Server. It implements conversations, messages, identities and
+// webhooks, and delivers signed webhooks to registered targets. The other
+// Layer APIs glare supports, such as receipts, badges, blocks, follows and
+// message parts, are answered with 501 Not Implemented so that a test
+// exercising them fails loudly. It is safe for concurrent use.
+type Server struct {
+	URL   string
+	AppID string
+	Token string
+
+	server        *httptest.Server
+	mu            sync.Mutex
+	conversations map[string]*glare.Conversation
+	messages      map[string][]glare.Message
+	identities    map[string]glare.Identity
+	webhooks      map[string]*fakeWebHook
+	webhookOrder  []string
+	position      int64
+}
+
+type fakeWebHook struct {
+	glare.WebHook
+	verified bool
+}
+
+type delivery struct {
+	target string
+	secret string
+	body   []byte
+}
+
+// effects collects the outgoing requests a route causes. They are recorded
+// while s.mu is held and sent once it is released, so that a target calling
+// back into the server cannot deadlock it.
+type effects struct {
+	deliveries []delivery
+	challenges []string
+}
+
+// NewServer starts a fake Layer server for the given app. Requests must carry
+// the given bearer token.
+func NewServer(appID string, token string) *Server {
+	s := &Server{
+		AppID:         appID,
+		Token:         token,
+		conversations: make(map[string]*glare.Conversation),
+		messages:      make(map[string][]glare.Message),
+		identities:    make(map[string]glare.Identity),
+		webhooks:      make(map[string]*fakeWebHook),
+	}
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Layer returns a glare client pointed at the fake server.
+func (s *Server) Layer() glare.Layer {
+	l := glare.New(s.AppID, s.Token, "3.0", glare.Backoff{})
+	l.BaseURL = s.URL
+	return l
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "authentication_required", "Missing or invalid bearer token")
+		return
+	}
+
+	method := r.Method
+	if override := r.Header.Get("X-HTTP-Method-Override"); len(override) > 0 {
+		method = override
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) < 3 || segments[0] != "apps" || segments[1] != s.AppID {
+		writeError(w, http.StatusNotFound, "not_found", "No such app")
+		return
+	}
+	route := segments[2:]
+
+	var fx effects
+	s.mu.Lock()
+	status, body := s.route(method, route, r, &fx)
+	s.mu.Unlock()
+
+	// Challenges are answered and webhooks delivered before responding so that
+	// tests can assert on them as soon as the glare call returns.
+	for _, id := range fx.challenges {
+		s.verify(id)
+	}
+	for _, d := range fx.deliveries {
+		deliver(d)
+	}
+
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// route dispatches a request to the matching resource. s.mu is held.
+func (s *Server) route(method string, route []string, r *http.Request, fx *effects) (int, interface{}) {
+	switch {
+	case route[0] == "conversations":
+		return s.routeConversations(method, route[1:], r, fx)
+	case route[0] == "messages" && len(route) == 2 && method == "GET":
+		return s.getMessage(route[1])
+	case route[0] == "messages" && len(route) >= 3 && route[2] == "parts":
+		return notImplemented("message parts")
+	case route[0] == "users" && len(route) >= 3:
+		return s.routeUser(method, route[1], route[2:], r)
+	case route[0] == "webhooks":
+		return s.routeWebHooks(method, route[1:], r, fx)
+	}
+
+	return errorBody(http.StatusNotFound, "not_found", "No such resource")
+}
+
+func (s *Server) routeConversations(method string, route []string, r *http.Request, fx *effects) (int, interface{}) {
+	switch {
+	case len(route) == 0 && method == "POST":
+		return s.createConversation(r, fx)
+	case len(route) == 1 && method == "GET":
+		c, ok := s.conversations[route[0]]
+		if !ok {
+			return errorBody(http.StatusNotFound, "not_found", "No such conversation")
+		}
+		return http.StatusOK, c
+	case len(route) == 1 && method == "PATCH":
+		return s.patchConversation(route[0], r, fx)
+	case len(route) == 1 && method == "DELETE":
+		return s.deleteConversation(route[0], fx)
+	case len(route) == 2 && route[1] == "messages" && method == "POST":
+		return s.sendMessage(route[0], r, fx)
+	case len(route) == 2 && route[1] == "messages" && method == "GET":
+		return s.listMessages(route[0], "", r.URL.Query())
+	case len(route) == 3 && route[1] == "messages" && method == "DELETE":
+		return s.deleteMessage(route[0], route[2], fx)
+	}
+
+	return errorBody(http.StatusNotFound, "not_found", "No such resource")
+}
+
+func (s *Server) routeUser(method string, userID string, route []string, r *http.Request) (int, interface{}) {
+	switch {
+	case route[0] == "identity" && len(route) == 1:
+		return s.routeIdentity(method, userID, r)
+	case route[0] == "conversations" && len(route) == 1 && method == "GET":
+		conversations := []glare.Conversation{}
+		for _, c := range s.sortedConversations() {
+			if contains(c.Participants, userID) {
+				conversations = append(conversations, *c)
+			}
+		}
+		return http.StatusOK, conversations
+	case route[0] == "conversations" && len(route) == 2 && method == "GET":
+		c, ok := s.conversations[route[1]]
+		if !ok || !contains(c.Participants, userID) {
+			return errorBody(http.StatusNotFound, "not_found", "No such conversation")
+		}
+		return http.StatusOK, c
+	case route[0] == "conversations" && len(route) == 3 && route[2] == "messages" && method == "GET":
+		return s.listMessages(route[1], userID, r.URL.Query())
+	case route[0] == "conversations" && len(route) == 3 && route[2] == "mark_all_read":
+		return notImplemented("marking conversations as read")
+	case route[0] == "messages" && len(route) == 3 && route[2] == "receipts":
+		return notImplemented("receipts")
+	case route[0] == "messages" && len(route) == 2:
+		return notImplemented("user messages")
+	case route[0] == "badge" && len(route) == 1:
+		return notImplemented("badges")
+	case route[0] == "blocks":
+		return notImplemented("blocks")
+	case route[0] == "identity" && len(route) >= 2 && route[1] == "following":
+		return notImplemented("follows")
+	}
+
+	return errorBody(http.StatusNotFound, "not_found", "No such resource")
+}
+
+func (s *Server) createConversation(r *http.Request, fx *effects) (int, interface{}) {
+	var pending glare.Conversation
+	if err := json.NewDecoder(r.Body).Decode(&pending); err != nil {
+		return errorBody(http.StatusBadRequest, "invalid_request", err.Error())
+	}
+
+	// Distinct conversations are unique per set of participants; creating one
+	// again returns the existing conversation unless the metadata conflicts.
+	if pending.Distinct {
+		for _, c := range s.conversations {
+			if c.Distinct && sameMembers(c.Participants, pending.Participants) {
+				if len(pending.MetaData) == 0 || reflect.DeepEqual(pending.MetaData, c.MetaData) {
+					return http.StatusOK, c
+				}
+				return http.StatusConflict, map[string]interface{}{
+					"id":      "conflict",
+					"code":    108,
+					"message": "A distinct conversation with these participants already exists",
+					"data":    c,
+				}
+			}
+		}
+	}
+
+	id := newUUID()
+	now := time.Now().UTC()
+	c := &glare.Conversation{
+		ID:           glare.ConversationID("layer:///conversations/" + id),
+		URL:          fmt.Sprintf("%s/apps/%s/conversations/%s", s.URL, s.AppID, id),
+		MessagesURL:  fmt.Sprintf("%s/apps/%s/conversations/%s/messages", s.URL, s.AppID, id),
+		CreatedAt:    &now,
+		Participants: pending.Participants,
+		MetaData:     pending.MetaData,
+		Distinct:     pending.Distinct,
+	}
+	s.conversations[id] = c
+	s.emit(glare.WebHookConversationCreated, "conversation", c, nil, fx)
+
+	return http.StatusCreated, c
+}
+
+func (s *Server) patchConversation(id string, r *http.Request, fx *effects) (int, interface{}) {
+	c, ok := s.conversations[id]
+	if !ok {
+		return errorBody(http.StatusNotFound, "not_found", "No such conversation")
+	}
+
+	var changes []glare.EditRequest
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		return errorBody(http.StatusBadRequest, "invalid_request", err.Error())
+	}
+
+	updated := *c
+	updated.MetaData = copyMetaData(c.MetaData)
+	updated.Participants = append([]string(nil), c.Participants...)
+	var participantsChanged, metaDataChanged bool
+	for _, change := range changes {
+		switch {
+		case change.Property == "participants":
+			values, err := stringValues(change.Value)
+			if err != nil {
+				return errorBody(http.StatusUnprocessableEntity, "invalid_property", err.Error())
+			}
+			switch change.Operation {
+			case glare.EditSet:
+				updated.Participants = values
+			case glare.EditAdd:
+				for _, v := range values {
+					if !contains(updated.Participants, v) {
+						updated.Participants = append(updated.Participants, v)
+					}
+				}
+			case glare.EditRemove:
+				updated.Participants = without(updated.Participants, values)
+			default:
+				return errorBody(http.StatusUnprocessableEntity, "invalid_operation", "Unsupported participants operation "+change.Operation)
+			}
+			participantsChanged = true
+		case change.Property == "metadata" || strings.HasPrefix(change.Property, "metadata."):
+			if err := applyMetaData(&updated.MetaData, change); err != nil {
+				return errorBody(http.StatusUnprocessableEntity, "invalid_property", err.Error())
+			}
+			metaDataChanged = true
+		default:
+			return errorBody(http.StatusUnprocessableEntity, "invalid_property", "Property "+change.Property+" cannot be changed")
+		}
+	}
+
+	*c = updated
+	if participantsChanged {
+		s.emit(glare.WebHookConversationUpdatedParticipants, "conversation", c, changes, fx)
+	}
+	if metaDataChanged {
+		s.emit(glare.WebHookConversationUpdatedMetaData, "conversation", c, changes, fx)
+	}
+
+	return http.StatusOK, c
+}
+
+func (s *Server) deleteConversation(id string, fx *effects) (int, interface{}) {
+	c, ok := s.conversations[id]
+	if !ok {
+		return errorBody(http.StatusNotFound, "not_found", "No such conversation")
+	}
+
+	delete(s.conversations, id)
+	delete(s.messages, id)
+	s.emit(glare.WebHookConversationDeleted, "conversation", c, nil, fx)
+
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) sendMessage(conversationID string, r *http.Request, fx *effects) (int, interface{}) {
+	c, ok := s.conversations[conversationID]
+	if !ok {
+		return errorBody(http.StatusNotFound, "not_found", "No such conversation")
+	}
+
+	var m glare.Message
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		return errorBody(http.StatusBadRequest, "invalid_request", err.Error())
+	}
+
+	if len(m.Parts) == 0 {
+		return errorBody(http.StatusUnprocessableEntity, "missing_property", "A message requires at least one part")
+	}
+
+	id := newUUID()
+	now := time.Now().UTC()
+	s.position++
+	m.ID = glare.MessageID("layer:///messages/" + id)
+	m.URL = fmt.Sprintf("%s/apps/%s/messages/%s", s.URL, s.AppID, id)
+	m.Position = s.position
+	m.SentAt = &now
+	m.ReceivedAt = &now
+	m.FromConversation.ID = c.ID
+	m.FromConversation.URL = c.URL
+	m.RecipientStatus = make(map[string]string)
+	for _, p := range c.Participants {
+		if p == m.Sender.UserID {
+			m.RecipientStatus[p] = glare.ReceiptRead
+		} else {
+			m.RecipientStatus[p] = "sent"
+		}
+	}
+
+	s.messages[conversationID] = append(s.messages[conversationID], m)
+	c.LastMessage = m
+	s.emit(glare.WebHookMessageSent, "message", m, nil, fx)
+
+	return http.StatusCreated, m
+}
+
+// listMessages returns messages newest first, optionally paginated with
+// page_size and from_id, from the perspective of the given user if set.
+func (s *Server) listMessages(conversationID string, userID string, query url.Values) (int, interface{}) {
+	c, ok := s.conversations[conversationID]
+	if !ok || (len(userID) > 0 && !contains(c.Participants, userID)) {
+		return errorBody(http.StatusNotFound, "not_found", "No such conversation")
+	}
+
+	pageSize := 100
+	if size, err := strconv.Atoi(query.Get("page_size")); err == nil && size > 0 {
+		pageSize = size
+	}
+
+	fromID := query.Get("from_id")
+	fromID = fromID[strings.LastIndex(fromID, "/")+1:]
+	messages := []glare.Message{}
+	all := s.messages[conversationID]
+	started := len(fromID) == 0
+	for i := len(all) - 1; i >= 0 && len(messages) < pageSize; i-- {
+		if !started {
+			started = all[i].ID.UUID() == fromID
+			continue
+		}
+
+		m := all[i]
+		if len(userID) > 0 {
+			m.IsUnread = m.RecipientStatus[userID] != glare.ReceiptRead
+		}
+		messages = append(messages, m)
+	}
+
+	return http.StatusOK, messages
+}
+
+func (s *Server) getMessage(id string) (int, interface{}) {
+	for _, messages := range s.messages {
+		for _, m := range messages {
+			if m.ID.UUID() == id {
+				return http.StatusOK, m
+			}
+		}
+	}
+
+	return errorBody(http.StatusNotFound, "not_found", "No such message")
+}
+
+func (s *Server) deleteMessage(conversationID string, messageID string, fx *effects) (int, interface{}) {
+	messages := s.messages[conversationID]
+	for i, m := range messages {
+		if m.ID.UUID() == messageID {
+			s.messages[conversationID] = append(messages[:i:i], messages[i+1:]...)
+			s.emit(glare.WebHookMessageDeleted, "message", m, nil, fx)
+			return http.StatusNoContent, nil
+		}
+	}
+
+	return errorBody(http.StatusNotFound, "not_found", "No such message")
+}
+
+func (s *Server) routeIdentity(method string, userID string, r *http.Request) (int, interface{}) {
+	current, exists := s.identities[userID]
+	switch method {
+	case "GET":
+		if !exists {
+			return errorBody(http.StatusNotFound, "not_found", "No such identity")
+		}
+		return http.StatusOK, current
+	case "POST":
+		if exists {
+			return errorBody(http.StatusConflict, "conflict", "Identity already exists")
+		}
+		var i glare.Identity
+		if err := json.NewDecoder(r.Body).Decode(&i); err != nil {
+			return errorBody(http.StatusBadRequest, "invalid_request", err.Error())
+		}
+		i.ID = glare.IdentityID("layer:///identities/" + userID)
+		i.URL = fmt.Sprintf("%s/apps/%s/users/%s/identity", s.URL, s.AppID, userID)
+		i.UserID = userID
+		s.identities[userID] = i
+		return http.StatusCreated, nil
+	case "PATCH":
+		if !exists {
+			return errorBody(http.StatusNotFound, "not_found", "No such identity")
+		}
+		var changes []glare.EditRequest
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			return errorBody(http.StatusBadRequest, "invalid_request", err.Error())
+		}
+		current.MetaData = copyMetaData(current.MetaData)
+		for _, change := range changes {
+			if err := applyIdentityChange(&current, change); err != nil {
+				return errorBody(http.StatusUnprocessableEntity, "invalid_property", err.Error())
+			}
+		}
+		s.identities[userID] = current
+		return http.StatusNoContent, nil
+	case "DELETE":
+		if !exists {
+			return errorBody(http.StatusNotFound, "not_found", "No such identity")
+		}
+		delete(s.identities, userID)
+		return http.StatusNoContent, nil
+	}
+
+	return errorBody(http.StatusMethodNotAllowed, "method_not_allowed", "Unsupported method")
+}
+
+func (s *Server) routeWebHooks(method string, route []string, r *http.Request, fx *effects) (int, interface{}) {
+	switch {
+	case len(route) == 0 && method == "POST":
+		return s.registerWebHook(r, fx)
+	case len(route) == 0 && method == "GET":
+		webhooks := []glare.WebHook{}
+		for _, id := range s.webhookOrder {
+			webhooks = append(webhooks, s.webhooks[id].WebHook)
+		}
+		return http.StatusOK, webhooks
+	}
+
+	w, ok := s.webhooks[route[0]]
+	if !ok {
+		return errorBody(http.StatusNotFound, "not_found", "No such webhook")
+	}
+
+	switch {
+	case len(route) == 1 && method == "GET":
+		return http.StatusOK, w.WebHook
+	case len(route) == 1 && method == "DELETE":
+		delete(s.webhooks, route[0])
+		for i, id := range s.webhookOrder {
+			if id == route[0] {
+				s.webhookOrder = append(s.webhookOrder[:i:i], s.webhookOrder[i+1:]...)
+				break
+			}
+		}
+		return http.StatusNoContent, nil
+	case len(route) == 2 && route[1] == "activate" && method == "POST":
+		if !w.verified {
+			return errorBody(http.StatusConflict, "unverified", "The webhook has not answered its verification challenge")
+		}
+		w.Status = glare.WebHookStatusActive
+		return http.StatusOK, w.WebHook
+	case len(route) == 2 && route[1] == "deactivate" && method == "POST":
+		if !w.verified {
+			return errorBody(http.StatusConflict, "unverified", "The webhook has not answered its verification challenge")
+		}
+		w.Status = glare.WebHookStatusInactive
+		return http.StatusOK, w.WebHook
+	}
+
+	return errorBody(http.StatusNotFound, "not_found", "No such resource")
+}
+
+// registerWebHook stores the unverified webhook and queues the verification
+// challenge Layer sends to its target. Like Layer, the response reports the
+// webhook as unverified.
+func (s *Server) registerWebHook(r *http.Request, fx *effects) (int, interface{}) {
+	var created glare.WebHook
+	if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+		return errorBody(http.StatusBadRequest, "invalid_request", err.Error())
+	}
+
+	if _, err := url.Parse(created.TargetURL); err != nil || len(created.TargetURL) == 0 {
+		return errorBody(http.StatusUnprocessableEntity, "invalid_property", "A valid target_url is required")
+	}
+
+	id := newUUID()
+	now := time.Now().UTC()
+	created.ID = id
+	created.URL = fmt.Sprintf("%s/apps/%s/webhooks/%s", s.URL, s.AppID, id)
+	created.CreatedAt = &now
+	created.Status = glare.WebHookStatusUnverified
+	w := &fakeWebHook{WebHook: created}
+
+	s.webhooks[id] = w
+	s.webhookOrder = append(s.webhookOrder, id)
+	fx.challenges = append(fx.challenges, id)
+
+	return http.StatusCreated, w.WebHook
+}
+
+// emit queues a signed delivery of the event to every active webhook that
+// subscribed to it. s.mu is held.
+func (s *Server) emit(eventType glare.WebHookEventType, resource string, object interface{}, changes []glare.EditRequest, fx *effects) {
+	now := time.Now().UTC()
+	payload := map[string]interface{}{
+		"event": glare.WebHookEvent{
+			ID:        "layer:///events/" + newUUID(),
+			CreatedAt: &now,
+			Type:      eventType,
+		},
+		resource: object,
+		"config": map[string]interface{}{},
+	}
+
+	if len(changes) > 0 {
+		webhookChanges := make([]glare.WebHookChange, len(changes))
+		for i, change := range changes {
+			webhookChanges[i] = glare.WebHookChange{Operation: change.Operation, Property: change.Property, Value: change.Value}
+		}
+		payload["changes"] = webhookChanges
+	}
+
+	for _, id := range s.webhookOrder {
+		w := s.webhooks[id]
+		if w.Status != glare.WebHookStatusActive || !contains(w.Events, string(eventType)) {
+			continue
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			continue
+		}
+		fx.deliveries = append(fx.deliveries, delivery{target: w.TargetURL, secret: w.Secret, body: body})
+	}
+}
+
+func (s *Server) sortedConversations() []*glare.Conversation {
+	conversations := make([]*glare.Conversation, 0, len(s.conversations))
+	for _, c := range s.conversations {
+		conversations = append(conversations, c)
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].CreatedAt.Before(*conversations[j].CreatedAt)
+	})
+
+	return conversations
+}
+
+// verify sends the verification challenge to the target of the webhook and
+// marks it as verified if it was echoed back. s.mu must not be held, since the
+// target may call back into the server.
+func (s *Server) verify(id string) {
+	s.mu.Lock()
+	w, ok := s.webhooks[id]
+	var target string
+	if ok {
+		target = w.TargetURL
+	}
+	s.mu.Unlock()
+
+	if !ok || !verifyTarget(target) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok = s.webhooks[id]; ok && !w.verified {
+		w.verified = true
+		w.Status = glare.WebHookStatusInactive
+	}
+}
+
+// verifyTarget sends a verification challenge to the target and reports
+// whether it was echoed back.
+func verifyTarget(target string) bool {
+	challenge := newUUID()
+	res, err := http.Get(target + separator(target) + "verification_challenge=" + challenge)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(res.Body)
+	return res.StatusCode == http.StatusOK && buf.String() == challenge
+}
+
+func deliver(d delivery) {
+	req, err := http.NewRequest("POST", d.target, bytes.NewReader(d.body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(glare.WebHookSignatureHeader, glare.WebHookSignature(d.secret, d.body))
+
+	res, err := http.DefaultClient.Do(req)
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+func applyIdentityChange(i *glare.Identity, change glare.EditRequest) error {
+	if change.Property == "metadata" || strings.HasPrefix(change.Property, "metadata.") {
+		return applyMetaData(&i.MetaData, change)
+	}
+
+	fields := map[string]*string{
+		"display_name":  &i.DisplayName,
+		"avatar_url":    &i.AvatarURL,
+		"first_name":    &i.FirstName,
+		"last_name":     &i.LastName,
+		"phone_number":  &i.Phone,
+		"email_address": &i.Email,
+		"public_key":    &i.PublicKey,
+	}
+	field, ok := fields[change.Property]
+	if !ok {
+		return fmt.Errorf("Property %s cannot be changed", change.Property)
+	}
+
+	switch change.Operation {
+	case glare.EditSet:
+		value, ok := change.Value.(string)
+		if !ok {
+			return fmt.Errorf("Property %s must be a string", change.Property)
+		}
+		*field = value
+	case glare.EditDelete:
+		*field = ""
+	default:
+		return fmt.Errorf("Unsupported operation %s", change.Operation)
+	}
+
+	return nil
+}
+
+func applyMetaData(metadata *map[string]interface{}, change glare.EditRequest) error {
+	if change.Property == "metadata" {
+		switch change.Operation {
+		case glare.EditSet:
+			value, ok := change.Value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("metadata must be an object")
+			}
+			*metadata = value
+		case glare.EditDelete:
+			*metadata = nil
+		default:
+			return fmt.Errorf("Unsupported metadata operation %s", change.Operation)
+		}
+		return nil
+	}
+
+	key := strings.TrimPrefix(change.Property, "metadata.")
+	switch change.Operation {
+	case glare.EditSet:
+		if *metadata == nil {
+			*metadata = make(map[string]interface{})
+		}
+		(*metadata)[key] = change.Value
+	case glare.EditDelete:
+		delete(*metadata, key)
+	default:
+		return fmt.Errorf("Unsupported metadata operation %s", change.Operation)
+	}
+
+	return nil
+}
+
+func errorBody(status int, id string, message string) (int, interface{}) {
+	return status, map[string]interface{}{"id": id, "code": status, "message": message}
+}
+
+// notImplemented answers a Layer API the fake does not implement.
+func notImplemented(feature string) (int, interface{}) {
+	return errorBody(http.StatusNotImplemented, "not_implemented", fmt.Sprintf("%s not implemented by glaretest", feature))
+}
+
+func writeError(w http.ResponseWriter, status int, id string, message string) {
+	_, body := errorBody(status, id, message)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func newUUID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16])
+}
+
+func copyMetaData(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+
+	copied := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		copied[k] = v
+	}
+
+	return copied
+}
+
+func stringValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("participants must be strings")
+			}
+			values[i] = s
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("participants must be a string or a list of strings")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func without(values []string, remove []string) []string {
+	var kept []string
+	for _, v := range values {
+		if !contains(remove, v) {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept
+}
+
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, v := range a {
+		if !contains(b, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func separator(target string) string {
+	if strings.Contains(target, "?") {
+		return "&"
+	}
+
+	return "?"
+}
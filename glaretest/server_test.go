@@ -0,0 +1,258 @@
+package glaretest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jtreleaven/glare"
+)
+
+// TestServerDistinctConversations should return the existing distinct
+// conversation for the same participants and reject conflicting metadata.
+func TestServerDistinctConversations(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	l := s.Layer()
+
+	pending := glare.Conversation{Participants: []string{"a", "b"}, Distinct: true}
+	created, err := l.CreateConversation(pending)
+	if err != nil {
+		t.Fatalf("Unable to create conversation: %s\n", err)
+	}
+
+	pending.Participants = []string{"b", "a"}
+	again, err := l.CreateConversation(pending)
+	if err != nil || again.ID != created.ID {
+		t.Logf("Expected distinct conversation %s, got %s: %v\n", created.ID, again.ID, err)
+		t.Fail()
+	}
+
+	pending.MetaData = map[string]interface{}{"title": "other"}
+	if _, err = l.CreateConversation(pending); err == nil {
+		t.Log("Expected a conflict for distinct conversation with different metadata")
+		t.Fail()
+	}
+
+	edited, err := l.EditConversation(created, []glare.EditRequest{
+		{Operation: glare.EditAdd, Property: "participants", Value: []string{"c"}},
+		{Operation: glare.EditSet, Property: "metadata.title", Value: "Lunch"},
+	})
+	if err != nil || len(edited.Participants) != 3 || edited.MetaData["title"] != "Lunch" {
+		t.Logf("Unexpected edited conversation %+v: %v\n", edited, err)
+		t.Fail()
+	}
+}
+
+// TestServerMessagePagination should page through messages newest first.
+func TestServerMessagePagination(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	l := s.Layer()
+
+	c, err := l.CreateConversation(glare.Conversation{Participants: []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("Unable to create conversation: %s\n", err)
+	}
+
+	var sent []glare.Message
+	for _, body := range []string{"one", "two", "three"} {
+		m := glare.Message{Parts: []glare.MessagePart{{Body: body, MimeType: "text/plain"}}}
+		m.Sender.UserID = "a"
+		created, err := l.SendMessage(m, c)
+		if err != nil {
+			t.Fatalf("Unable to send message: %s\n", err)
+		}
+		sent = append(sent, created)
+	}
+
+	page, err := l.RetrieveMessages(c, 2, "")
+	if err != nil || len(page) != 2 || page[0].ID != sent[2].ID || page[1].ID != sent[1].ID {
+		t.Logf("Unexpected first page %+v: %v\n", page, err)
+		t.Fail()
+	}
+
+	page, err = l.RetrieveMessages(c, 2, sent[1].ID)
+	if err != nil || len(page) != 1 || page[0].ID != sent[0].ID {
+		t.Logf("Unexpected second page %+v: %v\n", page, err)
+		t.Fail()
+	}
+
+	if _, err = l.GetMessage(sent[0].ID); err != nil {
+		t.Logf("Unable to fetch sent message: %s\n", err)
+		t.Fail()
+	}
+}
+
+// TestServerIdentityUpsert should create a missing identity and then patch it.
+func TestServerIdentityUpsert(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	l := s.Layer()
+
+	if _, err := l.RetrieveIdentity("u1"); err == nil {
+		t.Log("Expected a missing identity to be an error")
+		t.Fail()
+	}
+
+	created, err := l.UpsertIdentity("u1", glare.Identity{DisplayName: "One"})
+	if err != nil || !created {
+		t.Fatalf("Expected identity to be created: %v\n", err)
+	}
+
+	if _, err = l.UpsertIdentity("u1", glare.Identity{DisplayName: "Uno", MetaData: map[string]interface{}{"team": "x"}}); err != nil {
+		t.Fatalf("Unable to update identity: %s\n", err)
+	}
+
+	i, err := l.RetrieveIdentity("u1")
+	if err != nil || i.DisplayName != "Uno" || i.MetaData["team"] != "x" {
+		t.Logf("Unexpected identity %+v: %v\n", i, err)
+		t.Fail()
+	}
+}
+
+// TestServerEmitsWebHooks should deliver signed events to an active webhook.
+func TestServerEmitsWebHooks(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	l := s.Layer()
+
+	received := make(chan glare.Message, 1)
+	handler := &glare.WebHookHandler{
+		Secret: "shhh",
+		OnMessageSent: func(ctx context.Context, p glare.WebHookMessagePayload) error {
+			received <- p.Message
+			return nil
+		},
+	}
+	target := httptest.NewServer(handler)
+	defer target.Close()
+
+	_, err := l.RegisterAndActivateWebHook(glare.WebHook{
+		TargetURL: target.URL,
+		Events:    []string{string(glare.WebHookMessageSent)},
+		Secret:    "shhh",
+	}, nil, time.Second)
+	if err != nil {
+		t.Fatalf("Unable to activate webhook: %s\n", err)
+	}
+
+	c, err := l.CreateConversation(glare.Conversation{Participants: []string{"a"}})
+	if err != nil {
+		t.Fatalf("Unable to create conversation: %s\n", err)
+	}
+
+	sent, err := l.SendMessage(glare.Message{Parts: []glare.MessagePart{{Body: "hi", MimeType: "text/plain"}}}, c)
+	if err != nil {
+		t.Fatalf("Unable to send message: %s\n", err)
+	}
+
+	select {
+	case m := <-received:
+		if m.ID != sent.ID {
+			t.Logf("Expected webhook for %s, got %s\n", sent.ID, m.ID)
+			t.Fail()
+		}
+	default:
+		t.Log("Expected a message.sent webhook to have been delivered")
+		t.Fail()
+	}
+}
+
+// TestServerChallengeCallsBack should not deadlock when a webhook target calls
+// back into the server while answering its verification challenge.
+func TestServerChallengeCallsBack(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	l := s.Layer()
+
+	var listed int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhooks, err := l.ListWebHooks()
+		if err == nil {
+			listed = len(webhooks)
+		}
+		w.Write([]byte(r.URL.Query().Get("verification_challenge")))
+	}))
+	defer target.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.RegisterAndActivateWebHook(glare.WebHook{
+			TargetURL: target.URL,
+			Events:    []string{string(glare.WebHookMessageSent)},
+		}, nil, time.Second)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Logf("Unable to activate webhook: %s\n", err)
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Registering a webhook whose target calls back into the server deadlocked")
+	}
+
+	if listed != 1 {
+		t.Logf("Expected the target to list the webhook being verified, got %d\n", listed)
+		t.Fail()
+	}
+}
+
+// TestServerNotImplemented should answer the Layer APIs the fake lacks with
+// 501 rather than pretending they do not exist.
+func TestServerNotImplemented(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+
+	routes := []struct{ method, path string }{
+		{"GET", "/messages/m1/parts/p1"},
+		{"POST", "/users/a/conversations/c1/mark_all_read"},
+		{"POST", "/users/a/messages/m1/receipts"},
+		{"DELETE", "/users/a/messages/m1"},
+		{"GET", "/users/a/badge"},
+		{"GET", "/users/a/blocks"},
+		{"PUT", "/users/a/identity/following/b"},
+	}
+	for _, route := range routes {
+		req, _ := http.NewRequest(route.method, s.URL+"/apps/app"+route.path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s\n", err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusNotImplemented {
+			t.Logf("Expected 501 for %s %s, got %d\n", route.method, route.path, res.StatusCode)
+			t.Fail()
+		}
+	}
+}
+
+// TestServerDeactivateUnverified should refuse to deactivate a webhook that
+// has not answered its verification challenge, as it refuses to activate it.
+func TestServerDeactivateUnverified(t *testing.T) {
+	s := NewServer("app", "token")
+	defer s.Close()
+	l := s.Layer()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer target.Close()
+
+	w, err := l.RegisterWebHook(glare.WebHook{TargetURL: target.URL, Events: []string{string(glare.WebHookMessageSent)}})
+	if err != nil {
+		t.Fatalf("Unable to register webhook: %s\n", err)
+	}
+
+	if _, err = l.DeactivateWebHook(w); err == nil {
+		t.Log("Expected deactivating an unverified webhook to fail")
+		t.Fail()
+	}
+}
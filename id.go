@@ -0,0 +1,203 @@
+package glare
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const idScheme = "layer:///"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ConversationID identifies a conversation resource. It may hold either the
+// full layer:///conversations/<uuid> form or a bare UUID.
+type ConversationID string
+
+// MessageID identifies a message resource. It may hold either the full
+// layer:///messages/<uuid> form or a bare UUID.
+type MessageID string
+
+// IdentityID identifies an identity resource. It may hold either the full
+// layer:///identities/<user_id> form or a bare user ID.
+type IdentityID string
+
+// AnnouncementID identifies an announcement resource. It may hold either the
+// full layer:///announcements/<uuid> form or a bare UUID.
+type AnnouncementID string
+
+// ParseConversationID validates the given full or bare conversation ID and
+// returns it in its full form.
+func ParseConversationID(s string) (ConversationID, error) {
+	id, err := parseLayerID("conversations", s, true)
+	return ConversationID(id), err
+}
+
+// ParseMessageID validates the given full or bare message ID and returns it in
+// its full form.
+func ParseMessageID(s string) (MessageID, error) {
+	id, err := parseLayerID("messages", s, true)
+	return MessageID(id), err
+}
+
+// ParseIdentityID validates the given full identity ID or user ID and returns
+// it in its full form.
+func ParseIdentityID(s string) (IdentityID, error) {
+	id, err := parseLayerID("identities", s, false)
+	return IdentityID(id), err
+}
+
+// ParseAnnouncementID validates the given full or bare announcement ID and
+// returns it in its full form.
+func ParseAnnouncementID(s string) (AnnouncementID, error) {
+	id, err := parseLayerID("announcements", s, true)
+	return AnnouncementID(id), err
+}
+
+// UUID returns the bare UUID of the conversation.
+func (id ConversationID) UUID() string { return lastSegment(string(id)) }
+
+// String returns the full layer:///conversations/<uuid> form of the ID.
+func (id ConversationID) String() string { return formatLayerID("conversations", string(id)) }
+
+// MarshalJSON encodes a valid ID in its full form and anything else as is.
+func (id ConversationID) MarshalJSON() ([]byte, error) {
+	return marshalLayerID("conversations", string(id), true)
+}
+
+// UnmarshalJSON decodes a full or bare conversation ID. Unlike ParseConversationID it
+// keeps values it cannot validate, so an unexpected ID never fails a decode.
+func (id *ConversationID) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalLayerID("conversations", data, true)
+	*id = ConversationID(s)
+	return err
+}
+
+// UUID returns the bare UUID of the message.
+func (id MessageID) UUID() string { return lastSegment(string(id)) }
+
+// String returns the full layer:///messages/<uuid> form of the ID.
+func (id MessageID) String() string { return formatLayerID("messages", string(id)) }
+
+// MarshalJSON encodes a valid ID in its full form and anything else as is.
+func (id MessageID) MarshalJSON() ([]byte, error) {
+	return marshalLayerID("messages", string(id), true)
+}
+
+// UnmarshalJSON decodes a full or bare message ID. Unlike ParseMessageID it
+// keeps values it cannot validate, so an unexpected ID never fails a decode.
+func (id *MessageID) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalLayerID("messages", data, true)
+	*id = MessageID(s)
+	return err
+}
+
+// UserID returns the bare user ID of the identity.
+func (id IdentityID) UserID() string { return lastSegment(string(id)) }
+
+// String returns the full layer:///identities/<user_id> form of the ID.
+func (id IdentityID) String() string { return formatLayerID("identities", string(id)) }
+
+// MarshalJSON encodes a valid ID in its full form and anything else as is.
+func (id IdentityID) MarshalJSON() ([]byte, error) {
+	return marshalLayerID("identities", string(id), false)
+}
+
+// UnmarshalJSON decodes a full identity ID or bare user ID. Unlike
+// ParseIdentityID it keeps values it cannot validate.
+func (id *IdentityID) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalLayerID("identities", data, false)
+	*id = IdentityID(s)
+	return err
+}
+
+// UUID returns the bare UUID of the announcement.
+func (id AnnouncementID) UUID() string { return lastSegment(string(id)) }
+
+// String returns the full layer:///announcements/<uuid> form of the ID.
+func (id AnnouncementID) String() string { return formatLayerID("announcements", string(id)) }
+
+// MarshalJSON encodes a valid ID in its full form and anything else as is.
+func (id AnnouncementID) MarshalJSON() ([]byte, error) {
+	return marshalLayerID("announcements", string(id), true)
+}
+
+// UnmarshalJSON decodes a full or bare announcement ID. Unlike ParseAnnouncementID it
+// keeps values it cannot validate, so an unexpected ID never fails a decode.
+func (id *AnnouncementID) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalLayerID("announcements", data, true)
+	*id = AnnouncementID(s)
+	return err
+}
+
+// parseLayerID checks that s is either a bare value or a layer:/// ID of the
+// given resource kind and returns the full form. Bare values must be UUIDs
+// when requireUUID is set.
+func parseLayerID(kind string, s string, requireUUID bool) (string, error) {
+	value := s
+	if strings.HasPrefix(s, idScheme) {
+		prefix := idScheme + kind + "/"
+		if !strings.HasPrefix(s, prefix) {
+			return "", fmt.Errorf("%q is not a Layer %s ID", s, kind)
+		}
+		value = s[len(prefix):]
+	}
+
+	if len(value) == 0 || strings.Contains(value, "/") {
+		return "", fmt.Errorf("%q is not a valid Layer %s ID", s, kind)
+	}
+
+	if requireUUID && !uuidPattern.MatchString(value) {
+		return "", fmt.Errorf("%q does not contain a valid UUID", s)
+	}
+
+	return formatLayerID(kind, value), nil
+}
+
+// unmarshalLayerID decodes a JSON string holding a Layer ID. Empty strings and
+// null are accepted as the zero value. Valid IDs are returned in their full
+// form and anything else is returned unchanged.
+func unmarshalLayerID(kind string, data []byte, requireUUID bool) (string, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return "", err
+	}
+
+	return normalizeLayerID(kind, s, requireUUID), nil
+}
+
+// marshalLayerID encodes a Layer ID, in its full form if it is valid.
+func marshalLayerID(kind string, id string, requireUUID bool) ([]byte, error) {
+	return json.Marshal(normalizeLayerID(kind, id, requireUUID))
+}
+
+// normalizeLayerID returns the full form of a valid ID, or the ID unchanged.
+func normalizeLayerID(kind string, id string, requireUUID bool) string {
+	full, err := parseLayerID(kind, id, requireUUID)
+	if err != nil {
+		return id
+	}
+
+	return full
+}
+
+// trimIdentityID returns the user ID of a full identity ID, or the value
+// unchanged if it is already a bare user ID. Identity methods accept either.
+func trimIdentityID(id string) string {
+	return strings.TrimPrefix(id, idScheme+"identities/")
+}
+
+// formatLayerID returns the full layer:/// form of the given full or bare ID.
+func formatLayerID(kind string, id string) string {
+	if len(id) == 0 || strings.HasPrefix(id, idScheme) {
+		return id
+	}
+
+	return idScheme + kind + "/" + id
+}
+
+// lastSegment returns everything after the final slash of the given ID.
+func lastSegment(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
@@ -1,12 +1,151 @@
 package glare
 
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
 // Identity represents a single user resource from the Layer API
 type Identity struct {
-	DisplayName string                 `json:"display_name"`
-	AvatarURL   string                 `json:"avatar_url"`
-	FirstName   string                 `json:"first_name"`
-	LastName    string                 `json:"last_name"`
-	Phone       string                 `json:"phone_number"`
-	Email       string                 `json:"email_address"`
-	MetaData    map[string]interface{} `json:"metadata"`
+	ID           IdentityID             `json:"id,omitempty"`
+	URL          string                 `json:"url,omitempty"`
+	UserID       string                 `json:"user_id,omitempty"`
+	DisplayName  string                 `json:"display_name"`
+	AvatarURL    string                 `json:"avatar_url"`
+	FirstName    string                 `json:"first_name"`
+	LastName     string                 `json:"last_name"`
+	Phone        string                 `json:"phone_number"`
+	Email        string                 `json:"email_address"`
+	PublicKey    string                 `json:"public_key,omitempty"`
+	IdentityType string                 `json:"identity_type,omitempty"`
+	Presence     *Presence              `json:"presence,omitempty"`
+	MetaData     map[string]interface{} `json:"metadata"`
+}
+
+// Identity types reported by the Layer API.
+const (
+	IdentityTypeUser = "user"
+	IdentityTypeBot  = "bot"
+)
+
+// Presence statuses reported by the Layer API.
+const (
+	PresenceAvailable = "available"
+	PresenceBusy      = "busy"
+	PresenceAway      = "away"
+	PresenceOffline   = "offline"
+	PresenceInvisible = "invisible"
+)
+
+// Presence represents the last known presence of an identity. It is set by
+// Layer and is ignored when registering or updating an identity.
+type Presence struct {
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// Badge represents the unread counts Layer uses to compute the badge shown in
+// push notifications for a user.
+type Badge struct {
+	ExternalUnreadCount     int `json:"external_unread_count"`
+	UnreadConversationCount int `json:"unread_conversation_count,omitempty"`
+	UnreadMessageCount      int `json:"unread_message_count,omitempty"`
+}
+
+// IdentityChanges returns the EditRequests needed to turn current into
+// desired, replacing it entirely: fields that are empty in desired are
+// deleted, as are metadata keys it does not have. Use MergeIdentityChanges
+// when desired only holds the fields to change. Metadata is compared key by
+// key so that only the changed "metadata.<key>" properties are touched. The
+// public key is only ever set, never deleted, since most callers do not manage
+// it and leave it empty.
+func IdentityChanges(current, desired Identity) []EditRequest {
+	return identityChanges(current, desired, false)
+}
+
+// MergeIdentityChanges returns the EditRequests needed to merge desired into
+// current. Only the fields and metadata keys desired has are set; nothing is
+// ever deleted.
+func MergeIdentityChanges(current, desired Identity) []EditRequest {
+	return identityChanges(current, desired, true)
+}
+
+func identityChanges(current, desired Identity, merge bool) []EditRequest {
+	var changes []EditRequest
+	fields := []struct {
+		property string
+		from, to string
+		optional bool
+	}{
+		{"display_name", current.DisplayName, desired.DisplayName, merge},
+		{"avatar_url", current.AvatarURL, desired.AvatarURL, merge},
+		{"first_name", current.FirstName, desired.FirstName, merge},
+		{"last_name", current.LastName, desired.LastName, merge},
+		{"phone_number", current.Phone, desired.Phone, merge},
+		{"email_address", current.Email, desired.Email, merge},
+		{"public_key", current.PublicKey, desired.PublicKey, true},
+	}
+
+	for _, f := range fields {
+		if f.from == f.to || (f.optional && len(f.to) == 0) {
+			continue
+		}
+
+		if len(f.to) == 0 {
+			changes = append(changes, EditRequest{Operation: EditDelete, Property: f.property})
+		} else {
+			changes = append(changes, EditRequest{Operation: EditSet, Property: f.property, Value: f.to})
+		}
+	}
+
+	return append(changes, metaDataChanges(current.MetaData, desired.MetaData, merge)...)
+}
+
+// metaDataChanges diffs two metadata maps into nested EditRequests, sorted by
+// key so that the resulting patch is deterministic. Keys missing from desired
+// are deleted unless merging.
+func metaDataChanges(current, desired map[string]interface{}, merge bool) []EditRequest {
+	var changes []EditRequest
+	var keys []string
+	for key := range current {
+		if _, ok := desired[key]; !ok && !merge {
+			keys = append(keys, key)
+		}
+	}
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok := desired[key]
+		if !ok {
+			changes = append(changes, EditRequest{Operation: EditDelete, Property: "metadata." + key})
+		} else if !sameJSON(current[key], value) {
+			changes = append(changes, EditRequest{Operation: EditSet, Property: "metadata." + key, Value: value})
+		}
+	}
+
+	return changes
+}
+
+// sameJSON reports whether two metadata values are equal once both have been
+// through a JSON round trip, so that a desired int matches the float64 it is
+// decoded as when read back from Layer.
+func sameJSON(a, b interface{}) bool {
+	normalized := make([]interface{}, 2)
+	for i, value := range []interface{}{a, b} {
+		buf, err := json.Marshal(value)
+		if err != nil {
+			return reflect.DeepEqual(a, b)
+		}
+
+		if err = json.Unmarshal(buf, &normalized[i]); err != nil {
+			return reflect.DeepEqual(a, b)
+		}
+	}
+
+	return reflect.DeepEqual(normalized[0], normalized[1])
 }
@@ -0,0 +1,251 @@
+package glare
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Actions recorded in a SyncResult.
+const (
+	SyncCreated   = "created"
+	SyncUpdated   = "updated"
+	SyncDeleted   = "deleted"
+	SyncUnchanged = "unchanged"
+	SyncFailed    = "failed"
+)
+
+// IdentitySource provides the identities that should exist in Layer, keyed by
+// user ID. Next returns io.EOF once the source is exhausted.
+type IdentitySource interface {
+	Next() (string, Identity, error)
+}
+
+// SyncOptions configures a call to SyncIdentities.
+type SyncOptions struct {
+	// Concurrency is the number of users synced in parallel. Defaults to 1.
+	Concurrency int
+	// RequestsPerSecond caps the rate of requests made to Layer across all
+	// workers. Zero means no limit.
+	RequestsPerSecond int
+	// DryRun computes the report without making any changes in Layer.
+	DryRun bool
+	// Existing lists the user IDs known to have an identity in Layer. Any of
+	// them missing from the source are deleted.
+	Existing []string
+	// Replace makes each identity match the source exactly, deleting the
+	// fields and metadata keys the source leaves empty, as described by
+	// IdentityChanges. By default the source is merged into the identities
+	// and nothing is deleted from them.
+	Replace bool
+}
+
+// SyncResult describes what happened to a single user during a sync.
+type SyncResult struct {
+	UserID  string
+	Action  string
+	Changes []EditRequest
+	Err     error
+
+	seq int
+}
+
+// SyncReport is the per-user outcome of SyncIdentities, in source order with
+// deletions last.
+type SyncReport struct {
+	Results []SyncResult
+}
+
+// Count returns the number of users that ended with the given action.
+func (r SyncReport) Count(action string) int {
+	var count int
+	for _, result := range r.Results {
+		if result.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Failed returns the results of every user that could not be synced.
+func (r SyncReport) Failed() []SyncResult {
+	var failed []SyncResult
+	for _, result := range r.Results {
+		if result.Action == SyncFailed {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+type mapIdentitySource struct {
+	ids        []string
+	identities map[string]Identity
+}
+
+// NewMapIdentitySource returns an IdentitySource that yields the given
+// identities ordered by user ID.
+func NewMapIdentitySource(identities map[string]Identity) IdentitySource {
+	ids := make([]string, 0, len(identities))
+	for id := range identities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return &mapIdentitySource{ids: ids, identities: identities}
+}
+
+// Next implements the IdentitySource interface.
+func (s *mapIdentitySource) Next() (string, Identity, error) {
+	if len(s.ids) == 0 {
+		return "", Identity{}, io.EOF
+	}
+
+	id := s.ids[0]
+	s.ids = s.ids[1:]
+	return id, s.identities[id], nil
+}
+
+type syncJob struct {
+	seq      int
+	userID   string
+	identity Identity
+	remove   bool
+}
+
+// SyncIdentities mirrors every identity from the source into Layer, creating
+// missing users, patching changed ones and deleting users listed in
+// opts.Existing that the source no longer contains. Failures for individual
+// users are recorded in the report; the returned error is only set if the
+// source itself fails, in which case the report covers the users synced so far.
+func (l Layer) SyncIdentities(source IdentitySource, opts SyncOptions) (SyncReport, error) {
+	var report SyncReport
+	var sourceErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var throttle <-chan time.Time
+	if opts.RequestsPerSecond > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.RequestsPerSecond))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	jobs := make(chan syncJob)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				result := l.syncIdentity(job, opts, throttle)
+				mu.Lock()
+				report.Results = append(report.Results, result)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := make(map[string]bool)
+	var seq int
+	for {
+		userID, identity, err := source.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			sourceErr = err
+			break
+		}
+
+		seen[userID] = true
+		jobs <- syncJob{seq: seq, userID: userID, identity: identity}
+		seq++
+	}
+
+	// Only prune users once the whole source has been read, otherwise a
+	// failing source would delete everyone after the point of failure.
+	if sourceErr == nil {
+		for _, userID := range opts.Existing {
+			if !seen[userID] {
+				seen[userID] = true
+				jobs <- syncJob{seq: seq, userID: userID, remove: true}
+				seq++
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].seq < report.Results[j].seq
+	})
+
+	return report, sourceErr
+}
+
+// syncIdentity applies a single sync job, waiting on the throttle before each
+// request made to Layer.
+func (l Layer) syncIdentity(job syncJob, opts SyncOptions, throttle <-chan time.Time) SyncResult {
+	result := SyncResult{UserID: job.userID, seq: job.seq}
+	wait := func() {
+		if throttle != nil {
+			<-throttle
+		}
+	}
+
+	if job.remove {
+		result.Action = SyncDeleted
+		if !opts.DryRun {
+			wait()
+			if result.Err = l.DeleteIdentity(job.userID); result.Err != nil {
+				result.Action = SyncFailed
+			}
+		}
+		return result
+	}
+
+	wait()
+	current, err := l.RetrieveIdentity(job.userID)
+	if isNotFound(err) {
+		result.Action = SyncCreated
+		if !opts.DryRun {
+			wait()
+			if result.Err = l.RegisterIdentity(job.userID, job.identity); result.Err != nil {
+				result.Action = SyncFailed
+			}
+		}
+		return result
+	} else if err != nil {
+		result.Action = SyncFailed
+		result.Err = err
+		return result
+	}
+
+	if opts.Replace {
+		result.Changes = IdentityChanges(current, job.identity)
+	} else {
+		result.Changes = MergeIdentityChanges(current, job.identity)
+	}
+	if len(result.Changes) == 0 {
+		result.Action = SyncUnchanged
+		return result
+	}
+
+	result.Action = SyncUpdated
+	if !opts.DryRun {
+		wait()
+		if _, result.Err = l.UpdateIdentity(job.userID, result.Changes...); result.Err != nil {
+			result.Action = SyncFailed
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,103 @@
+package glare
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+// TestSyncIdentities should create missing users, patch changed users, leave
+// unchanged users alone and delete users that are no longer in the source.
+func TestSyncIdentities(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var created, patched, deleted int
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/A/identity",
+		httpmock.NewStringResponder(404, `{"id":"not_found"}`))
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/users/A/identity",
+		func(req *http.Request) (*http.Response, error) {
+			created++
+			return httpmock.NewStringResponse(201, ""), nil
+		},
+	)
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/identity",
+		httpmock.NewStringResponder(200, `{"display_name":"Bee"}`))
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/users/B/identity",
+		func(req *http.Request) (*http.Response, error) {
+			patched++
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/C/identity",
+		httpmock.NewStringResponder(200, `{"display_name":"Cee"}`))
+	httpmock.RegisterResponder("DELETE", "https://api.layer.com/apps/123/users/D/identity",
+		func(req *http.Request) (*http.Response, error) {
+			deleted++
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	source := NewMapIdentitySource(map[string]Identity{
+		"A": {DisplayName: "Ay"},
+		"B": {DisplayName: "B"},
+		"C": {DisplayName: "Cee"},
+	})
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	report, err := l.SyncIdentities(source, SyncOptions{Concurrency: 2, Existing: []string{"B", "C", "D"}})
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	expected := []string{SyncCreated, SyncUpdated, SyncUnchanged, SyncDeleted}
+	if len(report.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v\n", len(expected), report.Results)
+	}
+
+	for i, result := range report.Results {
+		if result.Action != expected[i] || result.Err != nil {
+			t.Logf("Unexpected result for %s: %+v\n", result.UserID, result)
+			t.Fail()
+		}
+	}
+
+	if created != 1 || patched != 1 || deleted != 1 {
+		t.Logf("Expected one create, patch and delete, got %d, %d, %d\n", created, patched, deleted)
+		t.Fail()
+	}
+}
+
+// TestSyncIdentitiesReplace should only delete the fields the source leaves
+// empty when replacing.
+func TestSyncIdentitiesReplace(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/users/B/identity",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(200, `{"display_name":"Bee","email_address":"b@example.com"}`), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	for _, replace := range []bool{false, true} {
+		source := NewMapIdentitySource(map[string]Identity{"B": {DisplayName: "B"}})
+		report, err := l.SyncIdentities(source, SyncOptions{DryRun: true, Replace: replace})
+		if err != nil || len(report.Results) != 1 {
+			t.Fatalf("Unexpected report %+v: %v\n", report, err)
+		}
+
+		expected := []EditRequest{{Operation: EditSet, Property: "display_name", Value: "B"}}
+		if replace {
+			expected = append(expected, EditRequest{Operation: EditDelete, Property: "email_address"})
+		}
+		if changes := report.Results[0].Changes; !reflect.DeepEqual(changes, expected) {
+			t.Logf("Unexpected changes with Replace %v: %+v\n", replace, changes)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package glare
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultIdentityTokenTTL is how long identity tokens remain valid when the
+// provider does not specify a TTL.
+const DefaultIdentityTokenTTL = 10 * time.Minute
+
+// IdentityTokenProvider mints the RS256 signed identity tokens that Layer's
+// client SDKs exchange for a session token.
+type IdentityTokenProvider struct {
+	ProviderID string
+	KeyID      string
+	TTL        time.Duration
+	// Logger, when set, receives the errors ChallengeHandler does not send
+	// back to the client.
+	Logger *log.Logger
+	key    *rsa.PrivateKey
+	now    func() time.Time
+}
+
+type identityTokenHeader struct {
+	Type        string `json:"typ"`
+	Algorithm   string `json:"alg"`
+	ContentType string `json:"cty"`
+	KeyID       string `json:"kid"`
+}
+
+type identityTokenClaims struct {
+	Issuer      string `json:"iss"`
+	Principal   string `json:"prn"`
+	IssuedAt    int64  `json:"iat"`
+	ExpiresAt   int64  `json:"exp"`
+	Nonce       string `json:"nce"`
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// NewIdentityTokenProvider returns a provider for the given Layer provider ID
+// and key ID that signs tokens with the given PEM encoded RSA private key. The
+// IDs may be given either in their full layer:/// form or as bare UUIDs.
+func NewIdentityTokenProvider(providerID string, keyID string, privateKeyPEM []byte) (*IdentityTokenProvider, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM encoded private key found")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, err
+		}
+
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("Private key is not an RSA key")
+		}
+	}
+
+	return &IdentityTokenProvider{
+		ProviderID: formatLayerID("providers", providerID),
+		KeyID:      formatLayerID("keys", keyID),
+		TTL:        DefaultIdentityTokenTTL,
+		key:        key,
+		now:        time.Now,
+	}, nil
+}
+
+// IdentityToken returns a signed identity token for the given user that answers
+// the given nonce. The display name and avatar of the identity, when set, are
+// included as claims so Layer can populate the user's identity.
+func (p *IdentityTokenProvider) IdentityToken(userID string, nonce string, i Identity) (string, error) {
+	if len(userID) == 0 {
+		return "", fmt.Errorf("A user id is required to create an identity token")
+	}
+
+	if err := validateNonce(nonce); err != nil {
+		return "", err
+	}
+
+	now := time.Now
+	if p.now != nil {
+		now = p.now
+	}
+	ttl := p.TTL
+	if ttl <= 0 {
+		ttl = DefaultIdentityTokenTTL
+	}
+	issuedAt := now()
+
+	header, err := encodeTokenSegment(identityTokenHeader{
+		Type:        "JWT",
+		Algorithm:   "RS256",
+		ContentType: "layer-eit;v=1",
+		KeyID:       p.KeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := encodeTokenSegment(identityTokenClaims{
+		Issuer:      p.ProviderID,
+		Principal:   userID,
+		IssuedAt:    issuedAt.Unix(),
+		ExpiresAt:   issuedAt.Add(ttl).Unix(),
+		Nonce:       nonce,
+		DisplayName: i.DisplayName,
+		AvatarURL:   i.AvatarURL,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := header + "." + claims
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ChallengeHandler returns an http.Handler serving the authentication challenge
+// endpoint used by the client SDKs. It accepts a POST with a JSON body of the
+// form {"nonce": "..."} and responds with {"identity_token": "..."}. The
+// authenticate function identifies the user making the request, typically from
+// the application's own session. Any error it returns is logged to Logger and
+// answered with a generic 401 so that no details leak to the client.
+func (p *IdentityTokenProvider) ChallengeHandler(authenticate func(r *http.Request) (string, Identity, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, identity, err := authenticate(r)
+		if err != nil {
+			if p.Logger != nil {
+				p.Logger.Println(err)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var challenge struct {
+			Nonce string `json:"nonce"`
+		}
+		if err = json.NewDecoder(r.Body).Decode(&challenge); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		token, err := p.IdentityToken(userID, challenge.Nonce, identity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			IdentityToken string `json:"identity_token"`
+		}{IdentityToken: token})
+	})
+}
+
+// validateNonce checks that the nonce handed out by Layer is usable as a claim.
+func validateNonce(nonce string) error {
+	if len(nonce) == 0 {
+		return fmt.Errorf("A nonce is required to create an identity token")
+	}
+
+	if strings.IndexFunc(nonce, func(r rune) bool { return r <= ' ' || r > '~' }) >= 0 {
+		return fmt.Errorf("Nonce %q contains invalid characters", nonce)
+	}
+
+	return nil
+}
+
+// encodeTokenSegment encodes the given header or claims as a JWT segment.
+func encodeTokenSegment(v interface{}) (string, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
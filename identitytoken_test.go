@@ -0,0 +1,101 @@
+package glare
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestIdentityToken should mint an RS256 token signed by the provider key and
+// carrying the claims Layer expects.
+func TestIdentityToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	p, err := NewIdentityTokenProvider("b2c8a6f2-3c97-11e4-baad-164230d1df67", "c3d9b7a3-3c97-11e4-baad-164230d1df67", keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.now = func() time.Time { return time.Unix(1500000000, 0) }
+
+	token, err := p.IdentityToken("frodo", "abc123", Identity{DisplayName: "Frodo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		t.Fatalf("Expected 3 token segments, got %d\n", len(segments))
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(segments[2])
+	digest := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	var claims identityTokenClaims
+	payload, _ := base64.RawURLEncoding.DecodeString(segments[1])
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := identityTokenClaims{
+		Issuer:      "layer:///providers/b2c8a6f2-3c97-11e4-baad-164230d1df67",
+		Principal:   "frodo",
+		IssuedAt:    1500000000,
+		ExpiresAt:   1500000000 + int64(DefaultIdentityTokenTTL/time.Second),
+		Nonce:       "abc123",
+		DisplayName: "Frodo",
+	}
+	if claims != expected {
+		t.Logf("Unexpected claims: %+v\n", claims)
+		t.Fail()
+	}
+
+	if _, err = p.IdentityToken("frodo", "", Identity{}); err == nil {
+		t.Log("Expected an empty nonce to be rejected")
+		t.Fail()
+	}
+}
+
+// TestChallengeHandlerUnauthorized should log why a user could not be
+// authenticated without sending the reason back to the client.
+func TestChallengeHandlerUnauthorized(t *testing.T) {
+	var logged bytes.Buffer
+	p := testIdentityTokenProvider(t)
+	p.Logger = log.New(&logged, "", 0)
+	handler := p.ChallengeHandler(func(r *http.Request) (string, Identity, error) {
+		return "", Identity{}, fmt.Errorf("session store at 10.0.0.5 is unreachable")
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/challenge", strings.NewReader(`{"nonce": "abc123"}`)))
+
+	if w.Code != http.StatusUnauthorized || strings.Contains(w.Body.String(), "10.0.0.5") {
+		t.Logf("Unexpected response %d: %q\n", w.Code, w.Body.String())
+		t.Fail()
+	}
+
+	if !strings.Contains(logged.String(), "10.0.0.5") {
+		t.Logf("Expected the error to be logged, got %q\n", logged.String())
+		t.Fail()
+	}
+}
@@ -6,15 +6,11 @@ import (
 
 // Message represents a single message resource from the Layer API
 type Message struct {
-	ID       string `json:"id,omitempty"`
-	URL      string `json:"url"`
-	IsUnread bool   `json:"is_unread"`
-	Parts    []struct {
-		ID       string                 `json:"id,omitempty"`
-		MimeType string                 `json:"mime_type"`
-		Content  map[string]interface{} `json:"content"`
-		Body     string                 `json:"body"`
-	} `json:"parts"`
+	ID              MessageID         `json:"id,omitempty"`
+	URL             string            `json:"url"`
+	Position        int64             `json:"position,omitempty"`
+	IsUnread        bool              `json:"is_unread"`
+	Parts           []MessagePart     `json:"parts"`
 	ReceivedAt      *time.Time        `json:"received_at,omitempty"`
 	RecipientStatus map[string]string `json:"recipient_status"`
 	Sender          struct {
@@ -23,7 +19,38 @@ type Message struct {
 	} `json:"sender"`
 	SentAt           *time.Time `json:"sent_at,omitempty"`
 	FromConversation struct {
-		ID  string `json:"id"`
-		URL string `json:"url"`
+		ID  ConversationID `json:"id"`
+		URL string         `json:"url"`
 	} `json:"conversation"`
 }
+
+// MessagePart represents a single part of a message resource from the Layer API
+type MessagePart struct {
+	ID       string                 `json:"id,omitempty"`
+	MimeType string                 `json:"mime_type"`
+	Content  map[string]interface{} `json:"content,omitempty"`
+	Body     string                 `json:"body"`
+}
+
+// Deletion modes accepted by the Layer API when deleting a message.
+// DeleteAllParticipants removes the message for everyone in the conversation,
+// DeleteMyDevices only removes it from the devices of a single user and
+// DeleteUserPerspective hides it from a single user without touching the
+// copies held by anyone else.
+const (
+	DeleteAllParticipants = "all_participants"
+	DeleteMyDevices       = "my_devices"
+	DeleteUserPerspective = "user_perspective"
+)
+
+// Receipt types accepted by the Layer API when marking a message on behalf of
+// a user.
+const (
+	ReceiptRead      = "read"
+	ReceiptDelivered = "delivered"
+)
+
+// Receipt represents the body of a receipt request to the Layer API.
+type Receipt struct {
+	Type string `json:"type"`
+}
@@ -0,0 +1,152 @@
+package glare
+
+import (
+	"time"
+)
+
+// ConversationService is the set of conversation methods of the Layer API. It
+// is implemented by Layer and lets consumers substitute a mock, such as the
+// ones in the glaremock package, in their unit tests.
+type ConversationService interface {
+	GetConversationsByUser(userID string) ([]Conversation, error)
+	GetConversationByUser(userID string, conversationID ConversationID) (Conversation, error)
+	GetConversationByID(conversationID ConversationID) (Conversation, error)
+	CreateConversation(pending Conversation) (Conversation, error)
+	EditConversation(c Conversation, changes []EditRequest) (Conversation, error)
+	DeleteConversation(remove Conversation) error
+}
+
+// MessageService is the set of message and receipt methods of the Layer API.
+type MessageService interface {
+	SendMessage(m Message, c Conversation) (Message, error)
+	GetMessage(id MessageID) (Message, error)
+	GetMessageByUser(userID string, id MessageID) (Message, error)
+	RetrieveMessages(c Conversation, pageSize int, fromID MessageID) ([]Message, error)
+	RetrieveMessagesByUser(userID string, c Conversation) ([]Message, error)
+	DeleteMessage(m Message, c Conversation) error
+	DeleteMessageWithMode(m Message, c Conversation, mode string) error
+	DeleteMessageByUser(userID string, m Message, mode string) error
+	AddMessagePart(m Message, part MessagePart) (MessagePart, error)
+	UpdateMessagePart(m Message, part MessagePart) error
+	DeleteMessagePart(m Message, part MessagePart) error
+	MarkMessageRead(userID string, m Message) error
+	MarkMessageDelivered(userID string, m Message) error
+	SendReceipt(userID string, m Message, receiptType string) error
+	MarkAllMessagesRead(userID string, c Conversation, position int64) error
+	GetUnreadMessageCount(userID string, c Conversation) (int, error)
+	GetUnreadMessageCounts(userID string) (map[string]int, error)
+}
+
+// IdentityService is the set of identity, badge, follow and block methods of
+// the Layer API.
+type IdentityService interface {
+	RegisterIdentity(id string, i Identity) error
+	UpdateIdentity(id string, changes ...EditRequest) (Identity, error)
+	UpsertIdentity(id string, i Identity) (bool, error)
+	RetrieveIdentity(id string) (Identity, error)
+	DeleteIdentity(id string) error
+	RetrieveBadge(id string) (Badge, error)
+	SetBadge(id string, externalUnreadCount int) error
+	FollowIdentity(id string, followID string) error
+	UnfollowIdentity(id string, followID string) error
+	ListFollowedIdentities(id string) ([]string, error)
+	SetFollowedIdentities(id string, followIDs []string) error
+	ListBlockedIdentities(id string) ([]Identity, error)
+	BlockIdentity(id string, blockID string) error
+	UnblockIdentity(id string, blockID string) error
+}
+
+// WebHookService is the set of webhook methods of the Layer API.
+type WebHookService interface {
+	RegisterWebHook(created WebHook) (WebHook, error)
+	RegisterAndActivateWebHook(created WebHook, responder *WebHookChallengeResponder, timeout time.Duration) (WebHook, error)
+	ListWebHooks() ([]WebHook, error)
+	GetWebHook(id string) (WebHook, error)
+	ActivateWebHook(w WebHook) (WebHook, error)
+	DeactivateWebHook(w WebHook) (WebHook, error)
+	DeleteWebHook(w WebHook) error
+}
+
+// ScopedConversationService is the set of methods of a ConversationsClient,
+// as returned by Layer.Conversations.
+type ScopedConversationService interface {
+	Get(conversationID ConversationID) (Conversation, error)
+	Create(pending Conversation) (Conversation, error)
+	Edit(c Conversation, changes []EditRequest) (Conversation, error)
+	Delete(remove Conversation) error
+}
+
+// ScopedMessageService is the set of methods of a MessagesClient, as returned
+// by Layer.Messages.
+type ScopedMessageService interface {
+	Send(m Message, c Conversation) (Message, error)
+	Get(id MessageID) (Message, error)
+	List(c Conversation, pageSize int, fromID MessageID) ([]Message, error)
+	Delete(m Message, c Conversation, mode string) error
+	AddPart(m Message, part MessagePart) (MessagePart, error)
+	UpdatePart(m Message, part MessagePart) error
+	DeletePart(m Message, part MessagePart) error
+}
+
+// ScopedIdentityService is the set of methods of an IdentitiesClient, as
+// returned by Layer.Identities.
+type ScopedIdentityService interface {
+	Register(id string, i Identity) error
+	Update(id string, changes ...EditRequest) (Identity, error)
+	Upsert(id string, i Identity) (bool, error)
+	Get(id string) (Identity, error)
+	Delete(id string) error
+}
+
+// ScopedWebHookService is the set of methods of a WebHooksClient, as returned
+// by Layer.WebHooks.
+type ScopedWebHookService interface {
+	Register(created WebHook) (WebHook, error)
+	RegisterAndActivate(created WebHook, responder *WebHookChallengeResponder, timeout time.Duration) (WebHook, error)
+	Reconcile(desired []WebHook, opts ReconcileOptions) (WebHookPlan, error)
+	List() ([]WebHook, error)
+	Get(id string) (WebHook, error)
+	Activate(w WebHook) (WebHook, error)
+	Deactivate(w WebHook) (WebHook, error)
+	Delete(w WebHook) error
+}
+
+// UserService is the set of methods of a UserView, as returned by
+// Layer.AsUser.
+type UserService interface {
+	UserID() string
+	Identity() (Identity, error)
+	Conversations() ([]Conversation, error)
+	Conversation(conversationID ConversationID) (Conversation, error)
+	Message(id MessageID) (Message, error)
+	Messages(c Conversation) ([]Message, error)
+	DeleteMessage(m Message, mode string) error
+	MarkRead(m Message) error
+	MarkDelivered(m Message) error
+	SendReceipt(m Message, receiptType string) error
+	MarkAllRead(c Conversation, position int64) error
+	UnreadCount(c Conversation) (int, error)
+	UnreadCounts() (map[string]int, error)
+	Badge() (Badge, error)
+	SetBadge(externalUnreadCount int) error
+	Follow(followID string) error
+	Unfollow(followID string) error
+	Following() ([]string, error)
+	SetFollowing(followIDs []string) error
+	Blocked() ([]Identity, error)
+	Block(blockID string) error
+	Unblock(blockID string) error
+}
+
+var (
+	_ ConversationService = Layer{}
+	_ MessageService      = Layer{}
+	_ IdentityService     = Layer{}
+	_ WebHookService      = Layer{}
+
+	_ ScopedConversationService = ConversationsClient{}
+	_ ScopedMessageService      = MessagesClient{}
+	_ ScopedIdentityService     = IdentitiesClient{}
+	_ ScopedWebHookService      = WebHooksClient{}
+	_ UserService               = UserView{}
+)
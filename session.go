@@ -0,0 +1,320 @@
+package glare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// SessionManager performs the Client API authentication handshake on behalf of
+// users and caches the resulting session tokens. It is safe for concurrent use.
+type SessionManager struct {
+	layer    Layer
+	provider *IdentityTokenProvider
+	mu       sync.Mutex
+	sessions map[string]string
+	// refreshing serializes the authentication of each user so that
+	// concurrent callers share a single session instead of each opening their
+	// own, without making users wait for each other.
+	refreshing map[string]*sync.Mutex
+}
+
+// NewSessionManager returns a SessionManager which authenticates users of the
+// Layer app with identity tokens minted by the given provider.
+func (l Layer) NewSessionManager(provider *IdentityTokenProvider) *SessionManager {
+	return &SessionManager{
+		layer:      l,
+		provider:   provider,
+		sessions:   make(map[string]string),
+		refreshing: make(map[string]*sync.Mutex),
+	}
+}
+
+// SessionToken returns the cached session token of the given user, performing
+// the nonce, identity token and session token exchange if there is none.
+func (s *SessionManager) SessionToken(userID string) (string, error) {
+	if token, ok := s.cached(userID); ok {
+		return token, nil
+	}
+
+	refresh := s.refreshLock(userID)
+	refresh.Lock()
+	defer refresh.Unlock()
+
+	// Another caller may have authenticated while we were waiting.
+	if token, ok := s.cached(userID); ok {
+		return token, nil
+	}
+
+	token, err := s.authenticate(userID)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.sessions[userID] = token
+	s.mu.Unlock()
+
+	return token, nil
+}
+
+// Invalidate forgets the cached session token of the given user so that the
+// next request authenticates again.
+func (s *SessionManager) Invalidate(userID string) {
+	s.mu.Lock()
+	delete(s.sessions, userID)
+	s.mu.Unlock()
+}
+
+// refreshLock returns the lock serializing the authentication of the user.
+func (s *SessionManager) refreshLock(userID string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.refreshing[userID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.refreshing[userID] = lock
+	}
+
+	return lock
+}
+
+// cached returns the session token of the given user if there is one.
+func (s *SessionManager) cached(userID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.sessions[userID]
+	return token, ok
+}
+
+// expire forgets the session token of the given user only if it is still the
+// given rejected token, so that a session refreshed by a concurrent request is
+// kept.
+func (s *SessionManager) expire(userID, token string) {
+	s.mu.Lock()
+	if s.sessions[userID] == token {
+		delete(s.sessions, userID)
+	}
+	s.mu.Unlock()
+}
+
+// UserClient returns a client which makes Client API requests as the given
+// user.
+func (s *SessionManager) UserClient(userID string) UserClient {
+	return UserClient{UserID: userID, sessions: s}
+}
+
+// authenticate requests a nonce from Layer, answers it with an identity token
+// for the user and exchanges that for a new session token.
+func (s *SessionManager) authenticate(userID string) (string, error) {
+	var nonce struct {
+		Nonce string `json:"nonce"`
+	}
+	url := fmt.Sprintf("%s/nonces", s.layer.baseURL())
+	res, err := makeSessionRequest("POST", url, "", s.layer.Version, struct{}{}, s.layer.Backoff)
+	if err != nil {
+		return "", err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&nonce); err != nil {
+		return "", err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return "", err
+	}
+
+	identityToken, err := s.provider.IdentityToken(userID, nonce.Nonce, Identity{})
+	if err != nil {
+		return "", err
+	}
+
+	var session struct {
+		SessionToken string `json:"session_token"`
+	}
+	body := struct {
+		IdentityToken string `json:"identity_token"`
+		AppID         string `json:"app_id"`
+	}{IdentityToken: identityToken, AppID: formatLayerID("apps", s.layer.ID)}
+	url = fmt.Sprintf("%s/sessions", s.layer.baseURL())
+	res, err = makeSessionRequest("POST", url, "", s.layer.Version, body, s.layer.Backoff)
+	if err != nil {
+		return "", err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&session); err != nil {
+		return "", err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return "", err
+	}
+
+	if len(session.SessionToken) == 0 {
+		return "", fmt.Errorf("Layer did not return a session token for user %s", userID)
+	}
+
+	return session.SessionToken, nil
+}
+
+// UserClient acts as a single user through the Layer Client API. Requests
+// rejected with a 401 are retried once with a fresh session token.
+type UserClient struct {
+	UserID   string
+	sessions *SessionManager
+}
+
+// GetConversations is the method for retrieving all conversations of the user.
+func (c UserClient) GetConversations() ([]Conversation, error) {
+	var conversations []Conversation
+	url := fmt.Sprintf("%s/conversations", c.sessions.layer.baseURL())
+	res, err := c.do(func(token string) (*http.Response, error) {
+		return makeSessionRequest("GET", url, token, c.sessions.layer.Version, nil, c.sessions.layer.Backoff)
+	})
+	if err != nil {
+		return conversations, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&conversations); err != nil {
+		return conversations, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return conversations, err
+	}
+
+	return conversations, nil
+}
+
+// GetConversation is the method for retrieving a single conversation of the
+// user.
+func (c UserClient) GetConversation(conversationID ConversationID) (Conversation, error) {
+	var conversation Conversation
+	url := fmt.Sprintf("%s/conversations/%s", c.sessions.layer.baseURL(), conversationID.UUID())
+	res, err := c.do(func(token string) (*http.Response, error) {
+		return makeSessionRequest("GET", url, token, c.sessions.layer.Version, nil, c.sessions.layer.Backoff)
+	})
+	if err != nil {
+		return conversation, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&conversation); err != nil {
+		return conversation, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return conversation, err
+	}
+
+	return conversation, nil
+}
+
+// RetrieveMessages will return the messages of the given conversation as seen
+// by the user.
+func (c UserClient) RetrieveMessages(conversation Conversation) ([]Message, error) {
+	var messages []Message
+	url := fmt.Sprintf("%s/conversations/%s/messages", c.sessions.layer.baseURL(), conversation.ID.UUID())
+	res, err := c.do(func(token string) (*http.Response, error) {
+		return makeSessionRequest("GET", url, token, c.sessions.layer.Version, nil, c.sessions.layer.Backoff)
+	})
+	if err != nil {
+		return messages, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&messages); err != nil {
+		return messages, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return messages, err
+	}
+
+	return messages, nil
+}
+
+// SendMessage will send the given message to the conversation as the user.
+func (c UserClient) SendMessage(m Message, conversation Conversation) (Message, error) {
+	var message Message
+	url := fmt.Sprintf("%s/conversations/%s/messages", c.sessions.layer.baseURL(), conversation.ID.UUID())
+	res, err := c.do(func(token string) (*http.Response, error) {
+		return makeSessionRequest("POST", url, token, c.sessions.layer.Version, m, c.sessions.layer.Backoff)
+	})
+	if err != nil {
+		return message, err
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&message); err != nil {
+		return message, err
+	}
+
+	if err = res.Body.Close(); err != nil {
+		return message, err
+	}
+
+	return message, nil
+}
+
+// MarkMessageRead will send a read receipt for the given message as the user.
+func (c UserClient) MarkMessageRead(m Message) error {
+	url := fmt.Sprintf("%s/messages/%s/receipts", c.sessions.layer.baseURL(), m.ID.UUID())
+	res, err := c.do(func(token string) (*http.Response, error) {
+		return makeSessionRequest("POST", url, token, c.sessions.layer.Version, Receipt{Type: ReceiptRead}, c.sessions.layer.Backoff)
+	})
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// do runs the given request with the user's session token, refreshing the
+// token and trying once more if Layer rejects it as expired.
+func (c UserClient) do(request func(token string) (*http.Response, error)) (*http.Response, error) {
+	token, err := c.sessions.SessionToken(c.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := request(token)
+	if errorStatusCode(err) != http.StatusUnauthorized {
+		return res, err
+	}
+
+	c.sessions.expire(c.UserID, token)
+	if token, err = c.sessions.SessionToken(c.UserID); err != nil {
+		return nil, err
+	}
+
+	return request(token)
+}
+
+// makeSessionRequest sends a Client API request authenticated with the given
+// session token. The Client API uses its own authorization scheme rather than
+// the bearer tokens of the Platform API, and the authentication handshake
+// itself is sent without a token.
+func makeSessionRequest(method string, url string, token string, version string, body interface{}, backoff Backoff) (*http.Response, error) {
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return &http.Response{}, err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(buf))
+	if err != nil {
+		return &http.Response{}, err
+	}
+	if len(token) > 0 {
+		req.Header.Add("Authorization", fmt.Sprintf("Layer session-token=%q", token))
+	}
+	req.Header.Add("Accept", fmt.Sprintf("application/vnd.layer+json; version=%s", version))
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	return backoff.Do(req)
+}
@@ -0,0 +1,201 @@
+package glare
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestUserClientRefreshesSession should authenticate through the nonce and
+// session endpoints and refresh the session token when Layer answers a 401.
+func TestUserClientRefreshesSession(t *testing.T) {
+	var nonces, sessions int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nonces", func(w http.ResponseWriter, r *http.Request) {
+		nonces++
+		w.WriteHeader(201)
+		fmt.Fprintf(w, `{"nonce":"nonce-%d"}`, nonces)
+	})
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			IdentityToken string `json:"identity_token"`
+			AppID         string `json:"app_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.IdentityToken) == 0 {
+			w.WriteHeader(422)
+			return
+		}
+		sessions++
+		w.WriteHeader(201)
+		fmt.Fprintf(w, `{"session_token":"session-%d"}`, sessions)
+	})
+	mux.HandleFunc("/conversations", func(w http.ResponseWriter, r *http.Request) {
+		// The first session handed out is treated as expired.
+		if r.Header.Get("Authorization") != `Layer session-token="session-2"` {
+			w.WriteHeader(401)
+			return
+		}
+		fmt.Fprint(w, `[{"id":"layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"}]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	l := New("123", "fjghfjshryfbus", "3.0", Backoff{})
+	l.BaseURL = server.URL
+	client := l.NewSessionManager(testIdentityTokenProvider(t)).UserClient("frodo")
+
+	conversations, err := client.GetConversations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conversations) != 1 || conversations[0].ID.UUID() != "f3cc7b32-3c92-11e4-baad-164230d1df67" {
+		t.Logf("Unexpected conversations: %+v\n", conversations)
+		t.Fail()
+	}
+
+	if nonces != 2 || sessions != 2 {
+		t.Logf("Expected two authentications, got %d nonces and %d sessions\n", nonces, sessions)
+		t.Fail()
+	}
+}
+
+// TestSessionTokenConcurrent should authenticate a user once even when many
+// callers ask for a session at the same time.
+func TestSessionTokenConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	var sessions int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nonces", func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Authorization")) > 0 {
+			w.WriteHeader(401)
+			return
+		}
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"nonce":"nonce"}`)
+	})
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sessions++
+		n := sessions
+		mu.Unlock()
+		w.WriteHeader(201)
+		fmt.Fprintf(w, `{"session_token":"session-%d"}`, n)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	l := New("123", "fjghfjshryfbus", "3.0", Backoff{})
+	l.BaseURL = server.URL
+	manager := l.NewSessionManager(testIdentityTokenProvider(t))
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := manager.SessionToken("frodo")
+			if err != nil {
+				t.Log(err)
+				t.Fail()
+			}
+			tokens[i] = token
+		}(i)
+	}
+	wg.Wait()
+
+	if sessions != 1 {
+		t.Logf("Expected a single authentication, got %d\n", sessions)
+		t.Fail()
+	}
+	for _, token := range tokens {
+		if token != "session-1" {
+			t.Logf("Unexpected session token %q\n", token)
+			t.Fail()
+		}
+	}
+}
+
+// TestSessionTokenUsersInParallel should let different users authenticate at
+// the same time.
+func TestSessionTokenUsersInParallel(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, peak int
+	both := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nonces", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"nonce":"nonce"}`)
+	})
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if inFlight++; inFlight > peak {
+			peak = inFlight
+		}
+		if peak == 2 && inFlight == 2 {
+			close(both)
+		}
+		mu.Unlock()
+
+		// Hold the first session until the other user asks for one too.
+		select {
+		case <-both:
+		case <-time.After(2 * time.Second):
+		}
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"session_token":"session"}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	l := New("123", "fjghfjshryfbus", "3.0", Backoff{})
+	l.BaseURL = server.URL
+	manager := l.NewSessionManager(testIdentityTokenProvider(t))
+
+	var wg sync.WaitGroup
+	for _, userID := range []string{"frodo", "sam"} {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			if _, err := manager.SessionToken(userID); err != nil {
+				t.Log(err)
+				t.Fail()
+			}
+		}(userID)
+	}
+	wg.Wait()
+
+	if peak != 2 {
+		t.Logf("Expected both users to authenticate at once, peak was %d\n", peak)
+		t.Fail()
+	}
+}
+
+// testIdentityTokenProvider returns a provider signing identity tokens with a
+// freshly generated key.
+func testIdentityTokenProvider(t *testing.T) *IdentityTokenProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	provider, err := NewIdentityTokenProvider("b2c8a6f2-3c97-11e4-baad-164230d1df67", "c3d9b7a3-3c97-11e4-baad-164230d1df67", keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
@@ -0,0 +1,139 @@
+package glare
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webHookPollInterval is how often RegisterAndActivateWebHook checks the status
+// of a newly registered webhook.
+var webHookPollInterval = time.Second
+
+// WebHookChallengeResponder is an http.Handler that echoes the
+// verification_challenge Layer sends to a newly registered webhook and signals
+// when challenges are answered. It can serve any number of registrations one
+// after the other; since challenges do not say which webhook they are for,
+// concurrent registrations need a responder each. The zero value is ready to
+// use.
+type WebHookChallengeResponder struct {
+	mu       sync.Mutex
+	answered int
+	next     chan struct{}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (c *WebHookChallengeResponder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !answerChallenge(w, r) {
+		return
+	}
+
+	c.mu.Lock()
+	c.answered++
+	if c.next != nil {
+		close(c.next)
+		c.next = nil
+	}
+	c.mu.Unlock()
+}
+
+// Answered returns a channel that is closed once a challenge has been
+// answered.
+func (c *WebHookChallengeResponder) Answered() <-chan struct{} {
+	return c.answeredAfter(0)
+}
+
+// count returns how many challenges have been answered so far.
+func (c *WebHookChallengeResponder) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.answered
+}
+
+// answeredAfter returns a channel that is closed once more than n challenges
+// have been answered.
+func (c *WebHookChallengeResponder) answeredAfter(n int) <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.answered > n {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+
+	if c.next == nil {
+		c.next = make(chan struct{})
+	}
+	return c.next
+}
+
+// answerChallenge writes the verification_challenge of the request back as the
+// response body and reports whether there was one to answer.
+func answerChallenge(w http.ResponseWriter, r *http.Request) bool {
+	challenge := r.URL.Query().Get("verification_challenge")
+	if len(challenge) == 0 {
+		http.Error(w, "missing verification_challenge", http.StatusBadRequest)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(challenge))
+	return true
+}
+
+// RegisterAndActivateWebHook registers the given webhook, waits for the given
+// responder to answer Layer's verification challenge, activates the webhook
+// and polls it until Layer reports it as active. The whole flow fails once the
+// timeout has passed. A nil responder skips waiting for the challenge, for
+// when it is answered by another process. A webhook that was registered is
+// not deleted when a later step fails: it is returned along with the error,
+// so that the caller can retry the activation or delete it.
+func (l Layer) RegisterAndActivateWebHook(created WebHook, responder *WebHookChallengeResponder, timeout time.Duration) (WebHook, error) {
+	deadline := time.After(timeout)
+
+	// Only challenges answered from now on can be for this webhook.
+	var answered int
+	if responder != nil {
+		answered = responder.count()
+	}
+
+	webhook, err := l.RegisterWebHook(created)
+	if err != nil {
+		return webhook, err
+	}
+
+	if responder != nil {
+		select {
+		case <-responder.answeredAfter(answered):
+		case <-deadline:
+			return webhook, fmt.Errorf("Timed out waiting for the verification challenge of webhook %s", webhook.ID)
+		}
+	}
+
+	if webhook.Status != WebHookStatusActive {
+		activated, err := l.ActivateWebHook(webhook)
+		if err != nil {
+			return webhook, err
+		}
+		webhook = activated
+	}
+
+	for webhook.Status != WebHookStatusActive {
+		select {
+		case <-time.After(webHookPollInterval):
+		case <-deadline:
+			return webhook, fmt.Errorf("Timed out waiting for webhook %s to become active, status is %q", webhook.ID, webhook.Status)
+		}
+
+		polled, err := l.GetWebHook(webhook.ID)
+		if err != nil {
+			return webhook, err
+		}
+		webhook = polled
+	}
+
+	return webhook, nil
+}
@@ -0,0 +1,106 @@
+package glare
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+)
+
+// TestRegisterAndActivateWebHook should wait for the challenge to be answered,
+// activate the webhook and poll it until it is active.
+func TestRegisterAndActivateWebHook(t *testing.T) {
+	webHookPollInterval = time.Millisecond
+	defer func() { webHookPollInterval = time.Second }()
+
+	responder := &WebHookChallengeResponder{}
+	var polls int
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks",
+		func(req *http.Request) (*http.Response, error) {
+			// Layer sends the verification challenge while registering.
+			go responder.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/webhooks?verification_challenge=abc", nil))
+			return httpmock.NewJsonResponse(201, WebHook{ID: "1", Status: WebHookStatusUnverified})
+		},
+	)
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks/1/activate",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, WebHook{ID: "1", Status: WebHookStatusInactive})
+		},
+	)
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/webhooks/1",
+		func(req *http.Request) (*http.Response, error) {
+			polls++
+			if polls < 3 {
+				return httpmock.NewJsonResponse(200, WebHook{ID: "1", Status: WebHookStatusInactive})
+			}
+			return httpmock.NewJsonResponse(200, WebHook{ID: "1", Status: WebHookStatusActive})
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	webhook, err := l.RegisterAndActivateWebHook(WebHook{TargetURL: "https://example.com/webhooks"}, responder, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if webhook.Status != WebHookStatusActive || polls != 3 {
+		t.Logf("Unexpected webhook %+v after %d polls\n", webhook, polls)
+		t.Fail()
+	}
+}
+
+// TestRegisterAndActivateWebHookReusesResponder should make each registration
+// wait for a challenge of its own when they share a responder.
+func TestRegisterAndActivateWebHookReusesResponder(t *testing.T) {
+	responder := &WebHookChallengeResponder{}
+	challenge := true
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks",
+		func(req *http.Request) (*http.Response, error) {
+			if challenge {
+				responder.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/webhooks?verification_challenge=abc", nil))
+			}
+			return httpmock.NewJsonResponse(201, WebHook{ID: "1", Status: WebHookStatusActive})
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	if _, err := l.RegisterAndActivateWebHook(WebHook{TargetURL: "https://example.com/webhooks"}, responder, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge = false
+	webhook, err := l.RegisterAndActivateWebHook(WebHook{TargetURL: "https://example.com/webhooks"}, responder, 50*time.Millisecond)
+	if err == nil || webhook.ID != "1" {
+		t.Logf("Expected a timeout for the unanswered challenge of webhook %+v: %v\n", webhook, err)
+		t.Fail()
+	}
+}
+
+// TestRegisterAndActivateWebHookActivationFails should return the registered
+// webhook along with the error so that it can be cleaned up.
+func TestRegisterAndActivateWebHookActivationFails(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks",
+		httpmock.NewStringResponder(201, `{"id":"1","status":"unverified"}`))
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks/1/activate",
+		httpmock.NewStringResponder(409, `{"id":"unverified"}`))
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	webhook, err := l.RegisterAndActivateWebHook(WebHook{TargetURL: "https://example.com/webhooks"}, nil, time.Second)
+	if err == nil || webhook.ID != "1" {
+		t.Logf("Expected the registered webhook with the error, got %+v: %v\n", webhook, err)
+		t.Fail()
+	}
+}
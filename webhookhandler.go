@@ -0,0 +1,292 @@
+package glare
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ErrWebHookInFlight is returned when a delivery repeats an event that is
+// still being dispatched. ServeHTTP answers it with a 409 so that Layer
+// retries the delivery once the first dispatch has finished.
+var ErrWebHookInFlight = fmt.Errorf("webhook event is already being dispatched")
+
+// WebHookHandler is an http.Handler that receives Layer webhook deliveries,
+// decodes them according to their event type and dispatches them to the
+// matching callback. Callbacks left nil are acknowledged without action,
+// deliveries that cannot be decoded are answered with a 400, and an error
+// returned by a callback is answered with a 500 so Layer retries the delivery.
+type WebHookHandler struct {
+	// Secret is used to verify the signature of every delivery. Deliveries
+	// are rejected when it is empty and Secrets is nil, unless
+	// InsecureSkipVerify is set.
+	Secret string
+	// Secrets, when set, is used instead of Secret so that deliveries signed
+	// with any active secret are accepted while a secret is rotated.
+	Secrets *WebHookSecrets
+	// InsecureSkipVerify accepts deliveries without checking their signature
+	// when no secret is configured. It should only be used in tests.
+	InsecureSkipVerify bool
+	// Challenge, when set, answers verification challenges so that a
+	// RegisterAndActivateWebHook call can tell when Layer reached the handler.
+	Challenge *WebHookChallengeResponder
+	// Seen, when set, drops deliveries whose Event.ID was already dispatched
+	// within SeenTTL, which defaults to DefaultSeenTTL, and rejects deliveries
+	// of an event that is still being dispatched with ErrWebHookInFlight.
+	// Events whose callback fails are forgotten so that a redelivery is
+	// dispatched again.
+	Seen    SeenStore
+	SeenTTL time.Duration
+	// OrderWindow, when positive, dispatches the events of each conversation
+	// one at a time in CreatedAt order. Every event is held for the window so
+	// that earlier events delivered late can still be dispatched first.
+	// ServeHTTP acknowledges such deliveries before the window and dispatches
+	// them in the background, so a failing callback is only reported to
+	// Logger; use a WebHookProcessor when failed events must be retried.
+	OrderWindow time.Duration
+	Logger      *log.Logger
+
+	OnMessageSent    func(ctx context.Context, p WebHookMessagePayload) error
+	OnMessageUpdated func(ctx context.Context, p WebHookMessagePayload) error
+	OnMessageDeleted func(ctx context.Context, p WebHookMessagePayload) error
+	// OnReceipt receives both delivery and read receipts; the Event.Type of
+	// the payload tells them apart.
+	OnReceipt             func(ctx context.Context, p WebHookReceiptPayload) error
+	OnConversationCreated func(ctx context.Context, p WebHookConversationPayload) error
+	// OnConversationUpdated receives every kind of conversation update; the
+	// Event.Type and Changes of the payload tell them apart.
+	OnConversationUpdated func(ctx context.Context, p WebHookConversationPayload) error
+	OnConversationDeleted func(ctx context.Context, p WebHookConversationPayload) error
+	OnIdentityCreated     func(ctx context.Context, p WebHookIdentityPayload) error
+	OnIdentityUpdated     func(ctx context.Context, p WebHookIdentityPayload) error
+	OnIdentityDeleted     func(ctx context.Context, p WebHookIdentityPayload) error
+	OnAnnouncementSent    func(ctx context.Context, p WebHookAnnouncementPayload) error
+
+	sequencer conversationSequencer
+}
+
+// ServeHTTP implements the http.Handler interface. GET requests answer the
+// verification challenge Layer sends when a webhook is registered and POST
+// requests are treated as deliveries.
+func (h *WebHookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		if h.Challenge != nil {
+			h.Challenge.ServeHTTP(w, r)
+		} else {
+			answerChallenge(w, r)
+		}
+	case "POST":
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !h.verify(body, r.Header.Get(WebHookSignatureHeader)) {
+			http.Error(w, "invalid webhook signature", http.StatusForbidden)
+			return
+		}
+
+		// Deliveries that cannot be decoded will never succeed, so they are
+		// rejected without asking Layer to retry them.
+		delivery, err := ParseWebHook(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if h.ordered(delivery) {
+			state, err := h.claim(delivery)
+			if err != nil {
+				h.fail(w, err)
+				return
+			}
+
+			if state == SeenNew {
+				go func() {
+					h.logError(h.finish(delivery, h.dispatchInOrder(context.Background(), delivery)))
+				}()
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if err = h.DispatchDelivery(r.Context(), delivery); err != nil {
+			h.fail(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// fail answers a delivery that could not be dispatched so that Layer retries
+// it.
+func (h *WebHookHandler) fail(w http.ResponseWriter, err error) {
+	if err == ErrWebHookInFlight {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (h *WebHookHandler) logError(err error) {
+	if err != nil && h.Logger != nil {
+		h.Logger.Println(err)
+	}
+}
+
+// verify checks the signature of a delivery against the configured secrets.
+func (h *WebHookHandler) verify(body []byte, header string) bool {
+	if h.Secrets != nil {
+		return h.Secrets.Verify(body, header)
+	} else if len(h.Secret) > 0 {
+		return VerifyWebHookSignature(h.Secret, body, header)
+	}
+
+	return h.InsecureSkipVerify
+}
+
+// Dispatch decodes a single webhook delivery body and calls the callback
+// registered for its event type.
+func (h *WebHookHandler) Dispatch(ctx context.Context, body []byte) error {
+	delivery, err := ParseWebHook(body)
+	if err != nil {
+		return err
+	}
+
+	return h.DispatchDelivery(ctx, delivery)
+}
+
+// DispatchDelivery calls the callback registered for the event type of an
+// already decoded delivery, applying deduplication and ordering when they are
+// configured. Events without a callback are ignored. With an OrderWindow the
+// call blocks until the event has been dispatched in order.
+func (h *WebHookHandler) DispatchDelivery(ctx context.Context, d WebHookDelivery) error {
+	state, err := h.claim(d)
+	if err != nil || state != SeenNew {
+		return err
+	}
+
+	return h.finish(d, h.dispatchInOrder(ctx, d))
+}
+
+// claim claims the event of the delivery when deduplication is configured.
+// Events that are still being dispatched are reported as ErrWebHookInFlight.
+func (h *WebHookHandler) claim(d WebHookDelivery) (SeenState, error) {
+	if h.Seen == nil || len(d.Event.ID) == 0 {
+		return SeenNew, nil
+	}
+
+	state, err := h.Seen.Claim(d.Event.ID, seenClaimTTL+h.OrderWindow)
+	if err == nil && state == SeenInFlight {
+		err = ErrWebHookInFlight
+	}
+
+	return state, err
+}
+
+// finish records a claimed event as processed once its dispatch succeeded, or
+// forgets it when the dispatch failed so that a redelivery is dispatched.
+func (h *WebHookHandler) finish(d WebHookDelivery, err error) error {
+	if h.Seen == nil || len(d.Event.ID) == 0 {
+		return err
+	}
+
+	if err != nil {
+		if forgetErr := h.Seen.Forget(d.Event.ID); forgetErr != nil {
+			return errors{err, forgetErr}
+		}
+		return err
+	}
+
+	ttl := h.SeenTTL
+	if ttl <= 0 {
+		ttl = DefaultSeenTTL
+	}
+
+	return h.Seen.Commit(d.Event.ID, ttl)
+}
+
+// ordered reports whether the delivery is sequenced behind the other events
+// of its conversation.
+func (h *WebHookHandler) ordered(d WebHookDelivery) bool {
+	return h.OrderWindow > 0 && len(deliveryConversation(d)) > 0
+}
+
+// dispatchInOrder sequences the delivery behind the other events of its
+// conversation when an OrderWindow is configured.
+func (h *WebHookHandler) dispatchInOrder(ctx context.Context, d WebHookDelivery) error {
+	if !h.ordered(d) {
+		return h.dispatch(ctx, d)
+	}
+
+	createdAt := time.Now()
+	if d.Event.CreatedAt != nil {
+		createdAt = *d.Event.CreatedAt
+	}
+
+	return h.sequencer.Do(deliveryConversation(d), createdAt, h.OrderWindow, func() error {
+		return h.dispatch(ctx, d)
+	})
+}
+
+// dispatch calls the callback registered for the event type of the delivery.
+func (h *WebHookHandler) dispatch(ctx context.Context, d WebHookDelivery) error {
+	switch d.Event.Type {
+	case WebHookMessageSent:
+		if h.OnMessageSent != nil {
+			return h.OnMessageSent(ctx, *d.Message)
+		}
+	case WebHookMessageUpdated:
+		if h.OnMessageUpdated != nil {
+			return h.OnMessageUpdated(ctx, *d.Message)
+		}
+	case WebHookMessageDeleted:
+		if h.OnMessageDeleted != nil {
+			return h.OnMessageDeleted(ctx, *d.Message)
+		}
+	case WebHookMessageDelivered, WebHookMessageRead:
+		if h.OnReceipt != nil {
+			return h.OnReceipt(ctx, *d.Receipt)
+		}
+	case WebHookConversationCreated:
+		if h.OnConversationCreated != nil {
+			return h.OnConversationCreated(ctx, *d.Conversation)
+		}
+	case WebHookConversationUpdated, WebHookConversationUpdatedParticipants, WebHookConversationUpdatedMetaData:
+		if h.OnConversationUpdated != nil {
+			return h.OnConversationUpdated(ctx, *d.Conversation)
+		}
+	case WebHookConversationDeleted:
+		if h.OnConversationDeleted != nil {
+			return h.OnConversationDeleted(ctx, *d.Conversation)
+		}
+	case WebHookIdentityCreated:
+		if h.OnIdentityCreated != nil {
+			return h.OnIdentityCreated(ctx, *d.Identity)
+		}
+	case WebHookIdentityUpdated:
+		if h.OnIdentityUpdated != nil {
+			return h.OnIdentityUpdated(ctx, *d.Identity)
+		}
+	case WebHookIdentityDeleted:
+		if h.OnIdentityDeleted != nil {
+			return h.OnIdentityDeleted(ctx, *d.Identity)
+		}
+	case WebHookAnnouncementSent:
+		if h.OnAnnouncementSent != nil {
+			return h.OnAnnouncementSent(ctx, *d.Announcement)
+		}
+	}
+
+	return nil
+}
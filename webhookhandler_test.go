@@ -0,0 +1,87 @@
+package glare
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWebHookHandler should answer the verification challenge and dispatch a
+// signed delivery to the callback for its event type.
+func TestWebHookHandler(t *testing.T) {
+	var sent WebHookMessagePayload
+	handler := &WebHookHandler{
+		Secret: "shhh",
+		OnMessageSent: func(ctx context.Context, p WebHookMessagePayload) error {
+			sent = p
+			return nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/webhooks?verification_challenge=abc", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "abc" {
+		t.Logf("Unexpected challenge response %d: %q\n", rec.Code, rec.Body.String())
+		t.Fail()
+	}
+
+	body := []byte(`{"event":{"id":"1","type":"message.sent"},"message":{"id":"layer:///messages/940de862-3c96-11e4-baad-164230d1df67"}}`)
+	req := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body))
+	req.Header.Set(WebHookSignatureHeader, WebHookSignature("shhh", body))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Logf("Unexpected delivery response %d: %q\n", rec.Code, rec.Body.String())
+		t.Fail()
+	}
+
+	if sent.Event.Type != WebHookMessageSent || sent.Message.ID.UUID() != "940de862-3c96-11e4-baad-164230d1df67" {
+		t.Logf("Unexpected payload dispatched: %+v\n", sent)
+		t.Fail()
+	}
+}
+
+// TestWebHookHandlerUnsigned should reject deliveries when no secret is
+// configured, unless verification is explicitly skipped.
+func TestWebHookHandlerUnsigned(t *testing.T) {
+	var calls int
+	handler := &WebHookHandler{
+		OnMessageSent: func(ctx context.Context, p WebHookMessagePayload) error {
+			calls++
+			return nil
+		},
+	}
+
+	body := []byte(`{"event":{"id":"1","type":"message.sent"}}`)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body)))
+	if rec.Code != http.StatusForbidden || calls != 0 {
+		t.Logf("Expected the unsigned delivery to be rejected, got %d after %d calls\n", rec.Code, calls)
+		t.Fail()
+	}
+
+	handler.InsecureSkipVerify = true
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK || calls != 1 {
+		t.Logf("Expected the unsigned delivery to be accepted, got %d after %d calls\n", rec.Code, calls)
+		t.Fail()
+	}
+}
+
+// TestWebHookHandlerUnknownEvent should acknowledge events of an unknown type
+// so that Layer does not retry them forever.
+func TestWebHookHandlerUnknownEvent(t *testing.T) {
+	handler := &WebHookHandler{InsecureSkipVerify: true}
+
+	body := []byte(`{"event":{"id":"1","type":"ring.destroyed"}}`)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Logf("Expected the unknown event to be acknowledged, got %d: %s\n", rec.Code, rec.Body)
+		t.Fail()
+	}
+}
@@ -0,0 +1,212 @@
+package glare
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSeenTTL is how long event IDs are remembered when WebHookHandler.SeenTTL
+// is not set. Layer stops retrying deliveries well within this time.
+const DefaultSeenTTL = 24 * time.Hour
+
+// seenClaimTTL is how long an event stays claimed while it is dispatched,
+// after which a process that died mid dispatch no longer holds it.
+const seenClaimTTL = 5 * time.Minute
+
+// SeenState describes what a SeenStore knows about an event ID.
+type SeenState int
+
+// States reported by SeenStore.Claim.
+const (
+	// SeenNew means the event was not seen before and is now claimed by the
+	// caller.
+	SeenNew SeenState = iota
+	// SeenInFlight means another caller claimed the event and has not
+	// finished processing it yet.
+	SeenInFlight
+	// SeenDone means the event was already processed.
+	SeenDone
+)
+
+// SeenStore remembers which webhook events are being or have been processed.
+// Implementations must be safe for concurrent use.
+type SeenStore interface {
+	// Claim reports the state of the event ID and, when it is new, records it
+	// as in flight for ttl.
+	Claim(id string, ttl time.Duration) (SeenState, error)
+	// Commit records a claimed event ID as processed for ttl.
+	Commit(id string, ttl time.Duration) error
+	// Forget removes the event ID so that a failed event can be processed
+	// again when it is redelivered.
+	Forget(id string) error
+}
+
+type memorySeenStore struct {
+	mu      sync.Mutex
+	entries map[string]seenEntry
+	sweep   time.Time
+}
+
+type seenEntry struct {
+	expires time.Time
+	done    bool
+}
+
+// NewMemorySeenStore returns a SeenStore that only lives as long as the process.
+func NewMemorySeenStore() SeenStore {
+	return &memorySeenStore{entries: make(map[string]seenEntry)}
+}
+
+// Claim implements the SeenStore interface.
+func (s *memorySeenStore) Claim(id string, ttl time.Duration) (SeenState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// Expired IDs are swept at most once a minute to keep claims cheap.
+	if now.After(s.sweep) {
+		for seen, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, seen)
+			}
+		}
+		s.sweep = now.Add(time.Minute)
+	}
+
+	if entry, ok := s.entries[id]; ok && now.Before(entry.expires) {
+		if entry.done {
+			return SeenDone, nil
+		}
+		return SeenInFlight, nil
+	}
+	s.entries[id] = seenEntry{expires: now.Add(ttl)}
+
+	return SeenNew, nil
+}
+
+// Commit implements the SeenStore interface.
+func (s *memorySeenStore) Commit(id string, ttl time.Duration) error {
+	s.mu.Lock()
+	s.entries[id] = seenEntry{expires: time.Now().Add(ttl), done: true}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Forget implements the SeenStore interface.
+func (s *memorySeenStore) Forget(id string) error {
+	s.mu.Lock()
+	delete(s.entries, id)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// conversationSequencer runs the events of each conversation one at a time in
+// CreatedAt order.
+type conversationSequencer struct {
+	mu    sync.Mutex
+	lanes map[ConversationID]*sequencerLane
+}
+
+type sequencerLane struct {
+	cond    *sync.Cond
+	busy    bool
+	pending []*sequencerTicket
+	users   int
+}
+
+type sequencerTicket struct {
+	createdAt time.Time
+}
+
+// Do holds the event for the given window so that earlier events delivered
+// late can overtake it, then runs fn once every earlier pending event of the
+// conversation has run.
+func (s *conversationSequencer) Do(conversationID ConversationID, createdAt time.Time, window time.Duration, fn func() error) error {
+	lane := s.acquire(conversationID)
+	defer s.release(conversationID)
+
+	ticket := &sequencerTicket{createdAt: createdAt}
+	lane.cond.L.Lock()
+	i := sort.Search(len(lane.pending), func(i int) bool {
+		return lane.pending[i].createdAt.After(createdAt)
+	})
+	// The head of a busy lane is already running, so even an older event
+	// has to wait behind it.
+	if lane.busy && i == 0 {
+		i = 1
+	}
+	lane.pending = append(lane.pending, nil)
+	copy(lane.pending[i+1:], lane.pending[i:])
+	lane.pending[i] = ticket
+	lane.cond.L.Unlock()
+
+	time.Sleep(window)
+
+	lane.cond.L.Lock()
+	for lane.busy || lane.pending[0] != ticket {
+		lane.cond.Wait()
+	}
+	lane.busy = true
+	lane.cond.L.Unlock()
+
+	err := fn()
+
+	lane.cond.L.Lock()
+	for i := range lane.pending {
+		if lane.pending[i] == ticket {
+			lane.pending = append(lane.pending[:i], lane.pending[i+1:]...)
+			break
+		}
+	}
+	lane.busy = false
+	lane.cond.Broadcast()
+	lane.cond.L.Unlock()
+
+	return err
+}
+
+func (s *conversationSequencer) acquire(conversationID ConversationID) *sequencerLane {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lanes == nil {
+		s.lanes = make(map[ConversationID]*sequencerLane)
+	}
+
+	lane, ok := s.lanes[conversationID]
+	if !ok {
+		lane = &sequencerLane{cond: sync.NewCond(&sync.Mutex{})}
+		s.lanes[conversationID] = lane
+	}
+	lane.users++
+
+	return lane
+}
+
+// release drops the lane of a conversation once no events are using it.
+func (s *conversationSequencer) release(conversationID ConversationID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lane := s.lanes[conversationID]
+	if lane.users--; lane.users == 0 {
+		delete(s.lanes, conversationID)
+	}
+}
+
+// deliveryConversation returns the conversation a delivery belongs to, if any.
+func deliveryConversation(d WebHookDelivery) ConversationID {
+	switch {
+	case d.Conversation != nil:
+		return d.Conversation.Conversation.ID
+	case d.Message != nil:
+		return d.Message.Message.FromConversation.ID
+	case d.Receipt != nil:
+		return d.Receipt.Message.FromConversation.ID
+	}
+
+	return ""
+}
@@ -0,0 +1,198 @@
+package glare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWebHookHandlerDeduplicates should dispatch each event ID once, unless the
+// first dispatch failed.
+func TestWebHookHandlerDeduplicates(t *testing.T) {
+	var calls int
+	handler := &WebHookHandler{
+		Seen: NewMemorySeenStore(),
+		OnMessageSent: func(ctx context.Context, p WebHookMessagePayload) error {
+			calls++
+			if calls == 1 {
+				return fmt.Errorf("downstream failure")
+			}
+			return nil
+		},
+	}
+
+	body := []byte(`{"event":{"id":"e1","type":"message.sent"}}`)
+	for i := 0; i < 3; i++ {
+		handler.Dispatch(context.Background(), body)
+	}
+
+	if calls != 2 {
+		t.Logf("Expected the event to be dispatched twice, got %d\n", calls)
+		t.Fail()
+	}
+}
+
+// TestWebHookHandlerOrdersConversationEvents should dispatch events of the same
+// conversation in CreatedAt order even when they are delivered out of order.
+func TestWebHookHandlerOrdersConversationEvents(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	handler := &WebHookHandler{
+		OrderWindow: 50 * time.Millisecond,
+		OnMessageSent: func(ctx context.Context, p WebHookMessagePayload) error {
+			mu.Lock()
+			order = append(order, p.Event.ID)
+			mu.Unlock()
+			return nil
+		},
+	}
+
+	delivery := `{"event":{"id":%q,"type":"message.sent","created_at":%q},
+		"message":{"conversation":{"id":"layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"}}}`
+	var wg sync.WaitGroup
+	for _, event := range []struct{ id, createdAt string }{
+		{"second", "2017-01-01T00:00:02Z"},
+		{"first", "2017-01-01T00:00:01Z"},
+	} {
+		wg.Add(1)
+		go func(id, createdAt string) {
+			defer wg.Done()
+			if err := handler.Dispatch(context.Background(), []byte(fmt.Sprintf(delivery, id, createdAt))); err != nil {
+				t.Log(err)
+				t.Fail()
+			}
+		}(event.id, event.createdAt)
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Logf("Unexpected dispatch order %v\n", order)
+		t.Fail()
+	}
+}
+
+// TestWebHookHandlerRejectsInFlightDuplicates should ask Layer to retry a
+// duplicate of an event that is still being dispatched, and acknowledge it
+// without dispatching once the first dispatch has finished.
+func TestWebHookHandlerRejectsInFlightDuplicates(t *testing.T) {
+	var calls int
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := &WebHookHandler{
+		Seen:               NewMemorySeenStore(),
+		InsecureSkipVerify: true,
+		OnMessageSent: func(ctx context.Context, p WebHookMessagePayload) error {
+			calls++
+			close(started)
+			<-release
+			return nil
+		},
+	}
+
+	body := []byte(`{"event":{"id":"e1","type":"message.sent"}}`)
+	done := make(chan error)
+	go func() {
+		done <- handler.Dispatch(context.Background(), body)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+	if w.Code != http.StatusConflict {
+		t.Logf("Expected the in flight duplicate to be rejected, got %d\n", w.Code)
+		t.Fail()
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+	if w.Code != http.StatusOK || calls != 1 {
+		t.Logf("Expected the processed duplicate to be acknowledged, got %d after %d calls\n", w.Code, calls)
+		t.Fail()
+	}
+}
+
+// TestWebHookHandlerAcknowledgesBeforeOrderWindow should answer ordered
+// deliveries right away and dispatch them once the window has passed.
+func TestWebHookHandlerAcknowledgesBeforeOrderWindow(t *testing.T) {
+	dispatched := make(chan string, 1)
+	handler := &WebHookHandler{
+		OrderWindow:        time.Second,
+		InsecureSkipVerify: true,
+		OnMessageSent: func(ctx context.Context, p WebHookMessagePayload) error {
+			dispatched <- p.Event.ID
+			return nil
+		},
+	}
+
+	body := []byte(`{"event":{"id":"e1","type":"message.sent","created_at":"2017-01-01T00:00:01Z"},
+		"message":{"conversation":{"id":"layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"}}}`)
+	start := time.Now()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+	if w.Code != http.StatusOK || time.Since(start) >= handler.OrderWindow {
+		t.Logf("Expected an immediate acknowledgement, got %d after %s\n", w.Code, time.Since(start))
+		t.Fail()
+	}
+
+	select {
+	case id := <-dispatched:
+		if id != "e1" {
+			t.Logf("Unexpected dispatched event %q\n", id)
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The delivery was never dispatched")
+	}
+}
+
+// TestConversationSequencerLateEvent should run an older event that arrives
+// while a newer one of the same conversation is running once the newer one
+// is done, instead of deadlocking the conversation.
+func TestConversationSequencerLateEvent(t *testing.T) {
+	var sequencer conversationSequencer
+	conversationID := ConversationID("layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67")
+	running := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan string, 3)
+
+	go func() {
+		sequencer.Do(conversationID, time.Unix(2, 0), 0, func() error {
+			close(running)
+			<-release
+			return nil
+		})
+		done <- "newer"
+	}()
+	<-running
+
+	for _, event := range []struct {
+		id        string
+		createdAt time.Time
+	}{{"older", time.Unix(1, 0)}, {"newest", time.Unix(3, 0)}} {
+		go func(id string, createdAt time.Time) {
+			sequencer.Do(conversationID, createdAt, 0, func() error { return nil })
+			done <- id
+		}(event.id, event.createdAt)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("The conversation deadlocked")
+		}
+	}
+}
@@ -0,0 +1,573 @@
+package glare
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueuedWebHook is a webhook delivery persisted for asynchronous processing.
+type QueuedWebHook struct {
+	ID         string          `json:"id"`
+	Body       json.RawMessage `json:"body"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error,omitempty"`
+	ReceivedAt time.Time       `json:"received_at"`
+	NotBefore  time.Time       `json:"not_before"`
+}
+
+// WebHookQueue stores webhook deliveries until they have been processed.
+// Implementations must be safe for concurrent use.
+type WebHookQueue interface {
+	// Enqueue adds a new delivery to the queue. A delivery whose ID is
+	// already queued is ignored, so that an event Layer delivers again is
+	// only processed once and keeps its attempts.
+	Enqueue(d QueuedWebHook) error
+	// Dequeue blocks until a delivery is due and reserves it for the caller.
+	Dequeue(ctx context.Context) (QueuedWebHook, error)
+	// Requeue stores the updated delivery and makes it available again once
+	// its NotBefore time has passed.
+	Requeue(d QueuedWebHook) error
+	// Ack removes a reserved delivery from the queue.
+	Ack(id string) error
+}
+
+// DeadLetterStore keeps deliveries that could not be processed so that they
+// can be inspected and replayed. Implementations must be safe for concurrent
+// use.
+type DeadLetterStore interface {
+	Put(d QueuedWebHook) error
+	List() ([]QueuedWebHook, error)
+	Remove(id string) error
+}
+
+// deliveryStore persists deliveries for the queue and dead-letter
+// implementations. The memory implementations use a nil store.
+type deliveryStore interface {
+	save(d QueuedWebHook) error
+	remove(id string) error
+	load() ([]QueuedWebHook, error)
+}
+
+type webHookQueue struct {
+	mu       sync.Mutex
+	store    deliveryStore
+	items    map[string]QueuedWebHook
+	reserved map[string]bool
+	notify   chan struct{}
+}
+
+// NewMemoryWebHookQueue returns a WebHookQueue that only lives as long as the
+// process.
+func NewMemoryWebHookQueue() WebHookQueue {
+	return &webHookQueue{
+		items:    make(map[string]QueuedWebHook),
+		reserved: make(map[string]bool),
+		notify:   make(chan struct{}),
+	}
+}
+
+// NewFileWebHookQueue returns a WebHookQueue that keeps each delivery as a JSON
+// file in dir. Deliveries left over from a previous process, including those
+// reserved but never acknowledged, are queued again.
+func NewFileWebHookQueue(dir string) (WebHookQueue, error) {
+	store, err := newFileDeliveryStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	q := NewMemoryWebHookQueue().(*webHookQueue)
+	q.store = store
+	for _, d := range deliveries {
+		q.items[d.ID] = d
+	}
+
+	return q, nil
+}
+
+// Enqueue implements the WebHookQueue interface.
+func (q *webHookQueue) Enqueue(d QueuedWebHook) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.items[d.ID]; ok {
+		return nil
+	}
+
+	if q.store != nil {
+		if err := q.store.save(d); err != nil {
+			return err
+		}
+	}
+	q.items[d.ID] = d
+	q.broadcast()
+
+	return nil
+}
+
+// Dequeue implements the WebHookQueue interface.
+func (q *webHookQueue) Dequeue(ctx context.Context) (QueuedWebHook, error) {
+	for {
+		q.mu.Lock()
+		next, wait, ok := q.next(time.Now())
+		if ok {
+			q.reserved[next.ID] = true
+			q.mu.Unlock()
+			return next, nil
+		}
+		notify := q.notify
+		q.mu.Unlock()
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return QueuedWebHook{}, ctx.Err()
+		case <-notify:
+		case <-due:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Requeue implements the WebHookQueue interface.
+func (q *webHookQueue) Requeue(d QueuedWebHook) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.store != nil {
+		if err := q.store.save(d); err != nil {
+			return err
+		}
+	}
+	q.items[d.ID] = d
+	delete(q.reserved, d.ID)
+	q.broadcast()
+
+	return nil
+}
+
+// Ack implements the WebHookQueue interface.
+func (q *webHookQueue) Ack(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.store != nil {
+		if err := q.store.remove(id); err != nil {
+			return err
+		}
+	}
+	delete(q.items, id)
+	delete(q.reserved, id)
+
+	return nil
+}
+
+// next returns the unreserved delivery that is due first. When none is due yet
+// it returns how long to wait for the earliest one, or 0 if the queue is empty.
+func (q *webHookQueue) next(now time.Time) (QueuedWebHook, time.Duration, bool) {
+	var next QueuedWebHook
+	var found bool
+	for id, d := range q.items {
+		if q.reserved[id] {
+			continue
+		}
+
+		if !found || d.NotBefore.Before(next.NotBefore) ||
+			(d.NotBefore.Equal(next.NotBefore) && d.ReceivedAt.Before(next.ReceivedAt)) {
+			next = d
+			found = true
+		}
+	}
+
+	if !found {
+		return next, 0, false
+	} else if next.NotBefore.After(now) {
+		return next, next.NotBefore.Sub(now), false
+	}
+
+	return next, 0, true
+}
+
+// broadcast wakes every goroutine blocked in Dequeue. q.mu must be held.
+func (q *webHookQueue) broadcast() {
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+type deadLetters struct {
+	mu    sync.Mutex
+	store deliveryStore
+	items map[string]QueuedWebHook
+}
+
+// NewMemoryDeadLetterStore returns a DeadLetterStore that only lives as long as
+// the process.
+func NewMemoryDeadLetterStore() DeadLetterStore {
+	return &deadLetters{items: make(map[string]QueuedWebHook)}
+}
+
+// NewFileDeadLetterStore returns a DeadLetterStore that keeps each delivery as
+// a JSON file in dir.
+func NewFileDeadLetterStore(dir string) (DeadLetterStore, error) {
+	store, err := newFileDeliveryStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &deadLetters{store: store, items: make(map[string]QueuedWebHook)}
+	for _, d := range deliveries {
+		s.items[d.ID] = d
+	}
+
+	return s, nil
+}
+
+// Put implements the DeadLetterStore interface.
+func (s *deadLetters) Put(d QueuedWebHook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.store != nil {
+		if err := s.store.save(d); err != nil {
+			return err
+		}
+	}
+	s.items[d.ID] = d
+
+	return nil
+}
+
+// List implements the DeadLetterStore interface. Deliveries are returned in
+// the order they were received.
+func (s *deadLetters) List() ([]QueuedWebHook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := make([]QueuedWebHook, 0, len(s.items))
+	for _, d := range s.items {
+		deliveries = append(deliveries, d)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ReceivedAt.Before(deliveries[j].ReceivedAt)
+	})
+
+	return deliveries, nil
+}
+
+// Remove implements the DeadLetterStore interface.
+func (s *deadLetters) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.store != nil {
+		if err := s.store.remove(id); err != nil {
+			return err
+		}
+	}
+	delete(s.items, id)
+
+	return nil
+}
+
+type fileDeliveryStore struct {
+	dir string
+}
+
+func newFileDeliveryStore(dir string) (*fileDeliveryStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &fileDeliveryStore{dir: dir}, nil
+}
+
+// save writes the delivery to a temporary file first so that a crash never
+// leaves a partially written delivery behind.
+func (s *fileDeliveryStore) save(d QueuedWebHook) error {
+	buf, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(d.ID)
+	if err = ioutil.WriteFile(path+".tmp", buf, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *fileDeliveryStore) remove(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *fileDeliveryStore) load() ([]QueuedWebHook, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []QueuedWebHook
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var d QueuedWebHook
+		if err = json.Unmarshal(buf, &d); err != nil {
+			return nil, fmt.Errorf("Unable to load webhook delivery %s: %s", file, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+// path names the file of a delivery after a hash of its ID, since event IDs
+// may contain characters that are not valid in file names.
+func (s *fileDeliveryStore) path(id string) string {
+	sum := sha1.Sum([]byte(id))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// WebHookProcessor acknowledges webhook deliveries as soon as they have been
+// persisted to a queue and processes them in the background with a pool of
+// workers. Failed deliveries are retried with exponential backoff and moved to
+// the dead-letter store once they run out of attempts.
+type WebHookProcessor struct {
+	Queue       WebHookQueue
+	DeadLetters DeadLetterStore
+	// Handler verifies, answers challenges for and dispatches the deliveries.
+	Handler *WebHookHandler
+	// Workers is the number of deliveries processed in parallel. Defaults to 1.
+	Workers int
+	// MaxAttempts is how many times a delivery is tried before it is
+	// dead-lettered. Defaults to 5.
+	MaxAttempts int
+	// MinDelay and MaxDelay bound the exponential backoff between attempts.
+	// They default to one second and one minute.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	Logger   *log.Logger
+}
+
+// ServeHTTP implements the http.Handler interface. Verification challenges are
+// answered directly while deliveries are verified, checked to be decodable and
+// enqueued before being acknowledged with a 202.
+func (p *WebHookProcessor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.Handler == nil {
+		http.Error(w, "webhook processor has no handler", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method != "POST" {
+		p.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !p.Handler.verify(body, r.Header.Get(WebHookSignatureHeader)) {
+		http.Error(w, "invalid webhook signature", http.StatusForbidden)
+		return
+	}
+
+	delivery, err := ParseWebHook(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	if err = p.Queue.Enqueue(QueuedWebHook{ID: deliveryID(delivery), Body: body, ReceivedAt: now, NotBefore: now}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Run processes queued deliveries until the context is cancelled.
+func (p *WebHookProcessor) Run(ctx context.Context) error {
+	if p.Handler == nil {
+		return fmt.Errorf("Unable to process webhooks without a Handler")
+	}
+
+	workers := p.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				d, err := p.Queue.Dequeue(ctx)
+				if err != nil {
+					return
+				}
+				p.process(ctx, d)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// ReplayDeadLetters moves the dead-lettered deliveries with the given IDs, or
+// every dead-lettered delivery if none are given, back onto the queue with
+// their attempts reset. It returns the number of deliveries replayed.
+func (p *WebHookProcessor) ReplayDeadLetters(ids ...string) (int, error) {
+	deliveries, err := p.DeadLetters.List()
+	if err != nil {
+		return 0, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var replayed int
+	for _, d := range deliveries {
+		if len(ids) > 0 && !wanted[d.ID] {
+			continue
+		}
+
+		d.Attempts = 0
+		d.LastError = ""
+		d.NotBefore = time.Now()
+		if err = p.Queue.Enqueue(d); err != nil {
+			return replayed, err
+		}
+
+		if err = p.DeadLetters.Remove(d.ID); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// process dispatches a single delivery and acknowledges, retries or
+// dead-letters it depending on the outcome.
+func (p *WebHookProcessor) process(ctx context.Context, d QueuedWebHook) {
+	err := p.Handler.Dispatch(ctx, d.Body)
+	if err == nil {
+		p.logError(p.Queue.Ack(d.ID))
+		return
+	}
+
+	d.Attempts++
+	d.LastError = err.Error()
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 5
+	}
+
+	if d.Attempts < maxAttempts {
+		d.NotBefore = time.Now().Add(p.delay(d.Attempts))
+		p.logError(p.Queue.Requeue(d))
+		return
+	}
+
+	if p.DeadLetters == nil {
+		p.logError(fmt.Errorf("Dropping webhook delivery %s after %d attempts: %s", d.ID, d.Attempts, d.LastError))
+		p.logError(p.Queue.Ack(d.ID))
+		return
+	}
+
+	if err = p.DeadLetters.Put(d); err != nil {
+		p.logError(err)
+		p.logError(p.Queue.Requeue(d))
+		return
+	}
+	p.logError(p.Queue.Ack(d.ID))
+}
+
+// delay returns the exponential backoff to wait after the given attempt.
+func (p *WebHookProcessor) delay(attempt int) time.Duration {
+	minDelay, maxDelay := p.MinDelay, p.MaxDelay
+	if minDelay <= 0 {
+		minDelay = time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = time.Minute
+	}
+
+	delay := minDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
+
+func (p *WebHookProcessor) logError(err error) {
+	if err != nil && p.Logger != nil {
+		p.Logger.Println(err)
+	}
+}
+
+// deliveryID returns the event ID of the delivery, or a random ID for the rare
+// delivery without one.
+func deliveryID(d WebHookDelivery) string {
+	if len(strings.TrimSpace(d.Event.ID)) > 0 {
+		return d.Event.ID
+	}
+
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,179 @@
+package glare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestWebHookProcessorRetriesAndDeadLetters should retry failing deliveries,
+// dead-letter the ones that never succeed and replay them on request.
+func TestWebHookProcessorRetriesAndDeadLetters(t *testing.T) {
+	attempts := make(chan string, 16)
+	var failures int
+	p := &WebHookProcessor{
+		Queue:       NewMemoryWebHookQueue(),
+		DeadLetters: NewMemoryDeadLetterStore(),
+		MaxAttempts: 3,
+		MinDelay:    time.Millisecond,
+		MaxDelay:    time.Millisecond,
+		Handler: &WebHookHandler{
+			InsecureSkipVerify: true,
+			OnMessageSent: func(ctx context.Context, payload WebHookMessagePayload) error {
+				attempts <- payload.Event.ID
+				if payload.Event.ID == "poison" {
+					return fmt.Errorf("downstream failure")
+				}
+				failures++
+				if failures < 2 {
+					return fmt.Errorf("transient failure")
+				}
+				return nil
+			},
+		},
+	}
+
+	for _, id := range []string{"flaky", "poison"} {
+		body := []byte(fmt.Sprintf(`{"event":{"id":%q,"type":"message.sent"}}`, id))
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body)))
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("Expected delivery to be accepted, got %d\n", rec.Code)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	counts := make(map[string]int)
+	for i := 0; i < 5; i++ {
+		select {
+		case id := <-attempts:
+			counts[id]++
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for attempts, got %+v\n", counts)
+		}
+	}
+
+	// Give the worker a moment to move the poison delivery aside.
+	deadline := time.Now().Add(time.Second)
+	var dead []QueuedWebHook
+	for time.Now().Before(deadline) {
+		if dead, _ = p.DeadLetters.List(); len(dead) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if counts["flaky"] != 2 || counts["poison"] != 3 {
+		t.Logf("Unexpected attempts %+v\n", counts)
+		t.Fail()
+	}
+
+	if len(dead) != 1 || dead[0].ID != "poison" || dead[0].Attempts != 3 || dead[0].LastError != "downstream failure" {
+		t.Fatalf("Unexpected dead letters %+v\n", dead)
+	}
+
+	replayed, err := p.ReplayDeadLetters()
+	if err != nil || replayed != 1 {
+		t.Logf("Expected one delivery replayed, got %d: %v\n", replayed, err)
+		t.Fail()
+	}
+
+	if dead, _ = p.DeadLetters.List(); len(dead) != 0 {
+		t.Logf("Expected dead letters to be empty, got %+v\n", dead)
+		t.Fail()
+	}
+}
+
+// TestFileWebHookQueueRecovers should return deliveries that were reserved but
+// never acknowledged once the queue is reopened.
+func TestFileWebHookQueueRecovers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glare-queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := NewFileWebHookQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = q.Enqueue(QueuedWebHook{ID: "layer:///events/1", Body: []byte(`{}`), ReceivedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = q.Dequeue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileWebHookQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	d, err := reopened.Dequeue(ctx)
+	if err != nil || d.ID != "layer:///events/1" {
+		t.Logf("Expected the delivery to be recovered, got %+v: %v\n", d, err)
+		t.Fail()
+	}
+}
+
+// TestWebHookQueueIgnoresDuplicates should keep the attempts of a queued
+// delivery when Layer delivers the same event again.
+func TestWebHookQueueIgnoresDuplicates(t *testing.T) {
+	q := NewMemoryWebHookQueue()
+	now := time.Now()
+
+	if err := q.Enqueue(QueuedWebHook{ID: "layer:///events/1", Body: []byte(`{}`), ReceivedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := q.Dequeue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Attempts = 3
+	if err = q.Requeue(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = q.Enqueue(QueuedWebHook{ID: "layer:///events/1", Body: []byte(`{}`), ReceivedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = q.Dequeue(context.Background())
+	if err != nil || d.Attempts != 3 {
+		t.Logf("Expected the queued delivery to keep its attempts, got %+v: %v\n", d, err)
+		t.Fail()
+	}
+}
+
+// TestWebHookProcessorWithoutHandler should fail instead of panicking when no
+// Handler is set.
+func TestWebHookProcessorWithoutHandler(t *testing.T) {
+	p := &WebHookProcessor{Queue: NewMemoryWebHookQueue()}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("POST", "/webhooks", bytes.NewReader([]byte(`{}`))))
+	if w.Code != http.StatusInternalServerError {
+		t.Logf("Expected a 500, got %d\n", w.Code)
+		t.Fail()
+	}
+
+	if err := p.Run(context.Background()); err == nil {
+		t.Log("Expected Run to fail without a Handler")
+		t.Fail()
+	}
+}
@@ -0,0 +1,279 @@
+package glare
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Actions a WebHookPlan can take.
+const (
+	PlanCreate       = "create"
+	PlanUpdate       = "update"
+	PlanDelete       = "delete"
+	PlanActivate     = "activate"
+	PlanDeactivate   = "deactivate"
+	PlanRotateSecret = "rotate_secret"
+)
+
+// WebHookPlanStep is a single change needed to reconcile the webhooks of an app.
+// Existing is nil for creations and Desired is nil for deletions.
+type WebHookPlanStep struct {
+	Action   string
+	Existing *WebHook
+	Desired  *WebHook
+	Reason   string
+}
+
+// WebHookPlan lists the changes ReconcileWebHooks makes, or would make when
+// run in dry-run mode.
+type WebHookPlan struct {
+	Steps []WebHookPlanStep
+}
+
+// String renders the plan with one step per line.
+func (p WebHookPlan) String() string {
+	if len(p.Steps) == 0 {
+		return "No webhook changes.\n"
+	}
+
+	var buf bytes.Buffer
+	for _, step := range p.Steps {
+		fmt.Fprintf(&buf, "%-13s %s", step.Action, planTarget(step))
+		if len(step.Reason) > 0 {
+			fmt.Fprintf(&buf, " (%s)", step.Reason)
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.String()
+}
+
+// ReconcileOptions configures a call to ReconcileWebHooks.
+type ReconcileOptions struct {
+	// DryRun only computes the plan without changing anything in Layer.
+	DryRun bool
+	// RotateSecrets replaces the secret of every desired webhook. Desired
+	// webhooks without a new secret get a randomly generated one.
+	RotateSecrets bool
+	// ActivateTimeout bounds how long a created or updated webhook may take to
+	// become active. Defaults to 30 seconds.
+	ActivateTimeout time.Duration
+	// Secrets, when set, is handed to RotateWebHookSecret for secret
+	// rotations so that a WebHookHandler sharing it accepts both secrets while
+	// Layer retries deliveries signed with the old one.
+	Secrets *WebHookSecrets
+	// SecretGrace is how long a rotated secret stays valid. Defaults to 24
+	// hours.
+	SecretGrace time.Duration
+}
+
+// ReconcileWebHooks makes the webhooks of the app match the desired ones.
+// Webhooks are matched by TargetURL: missing ones are created, ones whose
+// events, version, config or secret differ are replaced, ones not desired are
+// deleted and the remaining ones are activated or deactivated to match the
+// desired Status, which counts as active when empty. When several existing
+// webhooks share a target, the active one, or else the first, is kept and the
+// others are deleted. The plan is returned even when applying it fails part
+// way.
+func (l Layer) ReconcileWebHooks(desired []WebHook, opts ReconcileOptions) (WebHookPlan, error) {
+	existing, err := l.ListWebHooks()
+	if err != nil {
+		return WebHookPlan{}, err
+	}
+
+	plan, err := planWebHooks(existing, desired, opts.RotateSecrets)
+	if err != nil || opts.DryRun {
+		return plan, err
+	}
+
+	if opts.ActivateTimeout <= 0 {
+		opts.ActivateTimeout = 30 * time.Second
+	}
+	if opts.SecretGrace <= 0 {
+		opts.SecretGrace = 24 * time.Hour
+	}
+	if opts.Secrets == nil {
+		opts.Secrets = NewWebHookSecrets()
+	}
+
+	for _, step := range plan.Steps {
+		if err = l.applyWebHookStep(step, opts); err != nil {
+			return plan, fmt.Errorf("Unable to %s webhook %s: %s", step.Action, planTarget(step), err)
+		}
+	}
+
+	return plan, nil
+}
+
+// planWebHooks diffs the existing webhooks against the desired ones.
+func planWebHooks(existing []WebHook, desired []WebHook, rotateSecrets bool) (WebHookPlan, error) {
+	var plan WebHookPlan
+	byTarget := make(map[string]WebHook)
+	kept := make(map[string]int)
+	for i, w := range existing {
+		if k, ok := kept[w.TargetURL]; ok && (existing[k].Status == WebHookStatusActive || w.Status != WebHookStatusActive) {
+			continue
+		}
+		byTarget[w.TargetURL] = w
+		kept[w.TargetURL] = i
+	}
+
+	wanted := make(map[string]bool)
+	for i := range desired {
+		want := desired[i]
+		if wanted[want.TargetURL] {
+			return plan, fmt.Errorf("Webhook target %s is desired more than once", want.TargetURL)
+		}
+		wanted[want.TargetURL] = true
+
+		// The difference is taken before a secret is generated so that a
+		// rotation is not mistaken for a changed secret.
+		current, ok := byTarget[want.TargetURL]
+		reason := webHookDifference(current, want)
+		if rotateSecrets && (len(want.Secret) == 0 || (ok && want.Secret == current.Secret)) {
+			secret, err := newWebHookSecret()
+			if err != nil {
+				return plan, err
+			}
+			want.Secret = secret
+		}
+
+		if !ok {
+			plan.Steps = append(plan.Steps, WebHookPlanStep{Action: PlanCreate, Desired: &want})
+			continue
+		}
+
+		if len(reason) > 0 {
+			plan.Steps = append(plan.Steps, WebHookPlanStep{Action: PlanUpdate, Existing: &current, Desired: &want, Reason: reason})
+		} else if rotateSecrets {
+			plan.Steps = append(plan.Steps, WebHookPlanStep{Action: PlanRotateSecret, Existing: &current, Desired: &want})
+		} else if want.Status == WebHookStatusInactive && current.Status == WebHookStatusActive {
+			plan.Steps = append(plan.Steps, WebHookPlanStep{Action: PlanDeactivate, Existing: &current, Desired: &want})
+		} else if want.Status != WebHookStatusInactive && current.Status != WebHookStatusActive {
+			plan.Steps = append(plan.Steps, WebHookPlanStep{Action: PlanActivate, Existing: &current, Desired: &want, Reason: "status is " + current.Status})
+		}
+	}
+
+	for i := range existing {
+		if !wanted[existing[i].TargetURL] {
+			plan.Steps = append(plan.Steps, WebHookPlanStep{Action: PlanDelete, Existing: &existing[i]})
+		} else if kept[existing[i].TargetURL] != i {
+			plan.Steps = append(plan.Steps, WebHookPlanStep{Action: PlanDelete, Existing: &existing[i], Reason: "duplicate target"})
+		}
+	}
+
+	return plan, nil
+}
+
+// applyWebHookStep performs a single step of a plan. Layer webhooks cannot be
+// edited, so updates register the replacement before the old webhook is
+// deleted to avoid missing deliveries, and secret rotations go through
+// RotateWebHookSecret to keep the old secret valid for the grace period. An
+// update changing the secret adds the new one to opts.Secrets before the
+// replacement is registered and likewise keeps the old one for the grace
+// period.
+func (l Layer) applyWebHookStep(step WebHookPlanStep, opts ReconcileOptions) error {
+	switch step.Action {
+	case PlanCreate:
+		return l.registerDesiredWebHook(*step.Desired, opts.ActivateTimeout)
+	case PlanUpdate:
+		secretChanged := len(step.Desired.Secret) > 0 && step.Desired.Secret != step.Existing.Secret
+		if secretChanged {
+			opts.Secrets.Add(step.Desired.Secret, time.Time{})
+		}
+		if err := l.registerDesiredWebHook(*step.Desired, opts.ActivateTimeout); err != nil {
+			return err
+		}
+		if err := l.DeleteWebHook(*step.Existing); err != nil {
+			return err
+		}
+		if secretChanged && len(step.Existing.Secret) > 0 {
+			opts.Secrets.Add(step.Existing.Secret, time.Now().Add(opts.SecretGrace))
+		}
+		return nil
+	case PlanRotateSecret:
+		_, err := l.RotateWebHookSecret(*step.Existing, step.Desired.Secret, opts.Secrets, opts.SecretGrace, opts.ActivateTimeout)
+		return err
+	case PlanDelete:
+		return l.DeleteWebHook(*step.Existing)
+	case PlanActivate:
+		_, err := l.ActivateWebHook(*step.Existing)
+		return err
+	case PlanDeactivate:
+		_, err := l.DeactivateWebHook(*step.Existing)
+		return err
+	}
+
+	return fmt.Errorf("Unknown plan action %q", step.Action)
+}
+
+// registerDesiredWebHook registers the webhook and, unless it is desired to be
+// inactive, waits for it to become active.
+func (l Layer) registerDesiredWebHook(w WebHook, timeout time.Duration) error {
+	if w.Status == WebHookStatusInactive {
+		_, err := l.RegisterWebHook(w)
+		return err
+	}
+
+	_, err := l.RegisterAndActivateWebHook(w, nil, timeout)
+	return err
+}
+
+// webHookDifference describes why the existing webhook no longer matches the
+// desired one, or returns an empty string if it still does.
+func webHookDifference(existing, desired WebHook) string {
+	if !sameEvents(existing.Events, desired.Events) {
+		return "events changed"
+	}
+
+	if len(desired.Version) > 0 && desired.Version != existing.Version {
+		return "version changed"
+	}
+
+	if len(desired.Config) > 0 && !reflect.DeepEqual(desired.Config, existing.Config) {
+		return "config changed"
+	}
+
+	if len(desired.Secret) > 0 && len(existing.Secret) > 0 && desired.Secret != existing.Secret {
+		return "secret changed"
+	}
+
+	return ""
+}
+
+// sameEvents compares two event lists regardless of their order.
+func sameEvents(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+
+	return reflect.DeepEqual(sortedA, sortedB)
+}
+
+func planTarget(step WebHookPlanStep) string {
+	if step.Desired != nil {
+		return step.Desired.TargetURL
+	}
+
+	return step.Existing.TargetURL
+}
+
+// newWebHookSecret returns a random secret suitable for signing deliveries.
+func newWebHookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,254 @@
+package glare
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+)
+
+// TestReconcileWebHooksDryRun should plan creations, updates, activations and
+// deletions without changing anything in Layer.
+func TestReconcileWebHooksDryRun(t *testing.T) {
+	existing := []WebHook{
+		{ID: "1", TargetURL: "https://a.example.com", Status: WebHookStatusActive, Events: []string{string(WebHookMessageSent), string(WebHookMessageDeleted)}},
+		{ID: "2", TargetURL: "https://b.example.com", Status: WebHookStatusActive, Events: []string{string(WebHookMessageSent)}},
+		{ID: "3", TargetURL: "https://c.example.com", Status: WebHookStatusInactive, Events: []string{string(WebHookMessageSent)}},
+		{ID: "4", TargetURL: "https://d.example.com", Status: WebHookStatusActive},
+	}
+	desired := []WebHook{
+		{TargetURL: "https://a.example.com", Events: []string{string(WebHookMessageDeleted), string(WebHookMessageSent)}},
+		{TargetURL: "https://b.example.com", Events: []string{string(WebHookConversationCreated)}},
+		{TargetURL: "https://c.example.com", Events: []string{string(WebHookMessageSent)}},
+		{TargetURL: "https://e.example.com", Events: []string{string(WebHookMessageSent)}},
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/webhooks",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, existing)
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	plan, err := l.ReconcileWebHooks(desired, ReconcileOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct{ action, target string }{
+		{PlanUpdate, "https://b.example.com"},
+		{PlanActivate, "https://c.example.com"},
+		{PlanCreate, "https://e.example.com"},
+		{PlanDelete, "https://d.example.com"},
+	}
+	if len(plan.Steps) != len(expected) {
+		t.Fatalf("Unexpected plan:\n%s", plan)
+	}
+
+	for i, step := range plan.Steps {
+		if step.Action != expected[i].action || planTarget(step) != expected[i].target {
+			t.Logf("Unexpected step %d: %s %s\n", i, step.Action, planTarget(step))
+			t.Fail()
+		}
+	}
+}
+
+// TestReconcileWebHooksApply should create, replace, activate, deactivate and
+// delete webhooks in plan order.
+func TestReconcileWebHooksApply(t *testing.T) {
+	var calls []string
+	existing := []WebHook{
+		{ID: "1", TargetURL: "https://a.example.com", Status: WebHookStatusActive, Events: []string{string(WebHookMessageSent)}},
+		{ID: "2", TargetURL: "https://b.example.com", Status: WebHookStatusInactive, Events: []string{string(WebHookMessageSent)}},
+		{ID: "3", TargetURL: "https://c.example.com", Status: WebHookStatusActive, Events: []string{string(WebHookMessageSent)}},
+		{ID: "4", TargetURL: "https://d.example.com", Status: WebHookStatusActive},
+	}
+	desired := []WebHook{
+		{TargetURL: "https://a.example.com", Events: []string{string(WebHookConversationCreated)}},
+		{TargetURL: "https://b.example.com", Events: []string{string(WebHookMessageSent)}},
+		{TargetURL: "https://c.example.com", Status: WebHookStatusInactive, Events: []string{string(WebHookMessageSent)}},
+		{TargetURL: "https://e.example.com", Events: []string{string(WebHookMessageSent)}},
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockWebHooks(existing, &calls, nil)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	if _, err := l.ReconcileWebHooks(desired, ReconcileOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"POST https://a.example.com",
+		"DELETE 1",
+		"POST 2/activate",
+		"POST 3/deactivate",
+		"POST https://e.example.com",
+		"DELETE 4",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Logf("Unexpected calls %q\n", calls)
+		t.Fail()
+	}
+}
+
+// TestReconcileWebHooksRotateSecrets should rotate secrets through
+// RotateWebHookSecret so that the old secret stays valid for the grace period.
+func TestReconcileWebHooksRotateSecrets(t *testing.T) {
+	var calls []string
+	var registered []WebHook
+	existing := []WebHook{
+		{ID: "1", TargetURL: "https://a.example.com", Status: WebHookStatusActive, Events: []string{string(WebHookMessageSent)}, Secret: "old"},
+	}
+	desired := []WebHook{
+		{TargetURL: "https://a.example.com", Events: []string{string(WebHookMessageSent)}},
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockWebHooks(existing, &calls, &registered)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	secrets := NewWebHookSecrets("old")
+	plan, err := l.ReconcileWebHooks(desired, ReconcileOptions{RotateSecrets: true, Secrets: secrets})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Steps) != 1 || plan.Steps[0].Action != PlanRotateSecret || len(registered) != 1 {
+		t.Fatalf("Unexpected plan:\n%s", plan)
+	}
+
+	if expected := []string{"POST https://a.example.com", "DELETE 1"}; !reflect.DeepEqual(calls, expected) {
+		t.Logf("Unexpected calls %q\n", calls)
+		t.Fail()
+	}
+
+	active := secrets.Active()
+	sort.Strings(active)
+	expected := []string{registered[0].Secret, "old"}
+	sort.Strings(expected)
+	if registered[0].Secret == "old" || !reflect.DeepEqual(active, expected) {
+		t.Logf("Unexpected active secrets %v after registering %+v\n", active, registered[0])
+		t.Fail()
+	}
+}
+
+// TestReconcileWebHooksDuplicateTargets should keep the active webhook of a
+// target registered more than once and delete the others.
+func TestReconcileWebHooksDuplicateTargets(t *testing.T) {
+	existing := []WebHook{
+		{ID: "1", TargetURL: "https://a.example.com", Status: WebHookStatusInactive, Events: []string{string(WebHookMessageSent)}},
+		{ID: "2", TargetURL: "https://a.example.com", Status: WebHookStatusActive, Events: []string{string(WebHookMessageSent)}},
+		{ID: "3", TargetURL: "https://a.example.com", Status: WebHookStatusActive, Events: []string{string(WebHookMessageSent)}},
+	}
+	desired := []WebHook{
+		{TargetURL: "https://a.example.com", Events: []string{string(WebHookMessageSent)}},
+	}
+
+	plan, err := planWebHooks(existing, desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deleted []string
+	for _, step := range plan.Steps {
+		if step.Action != PlanDelete {
+			t.Fatalf("Unexpected plan:\n%s", plan)
+		}
+		deleted = append(deleted, step.Existing.ID)
+	}
+	if expected := []string{"1", "3"}; !reflect.DeepEqual(deleted, expected) {
+		t.Logf("Expected webhooks %v to be deleted, got %v\n", expected, deleted)
+		t.Fail()
+	}
+}
+
+// TestReconcileWebHooksSecretChanged should hand the new secret of a replaced
+// webhook to the handler secrets, keeping the old one for the grace period.
+func TestReconcileWebHooksSecretChanged(t *testing.T) {
+	var calls []string
+	existing := []WebHook{
+		{ID: "1", TargetURL: "https://a.example.com", Status: WebHookStatusActive, Events: []string{string(WebHookMessageSent)}, Secret: "old"},
+	}
+	desired := []WebHook{
+		{TargetURL: "https://a.example.com", Events: []string{string(WebHookMessageSent)}, Secret: "new"},
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	mockWebHooks(existing, &calls, nil)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	secrets := NewWebHookSecrets("old")
+	plan, err := l.ReconcileWebHooks(desired, ReconcileOptions{Secrets: secrets})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Steps) != 1 || plan.Steps[0].Action != PlanUpdate {
+		t.Fatalf("Unexpected plan:\n%s", plan)
+	}
+
+	active := secrets.Active()
+	sort.Strings(active)
+	if expected := []string{"new", "old"}; !reflect.DeepEqual(active, expected) {
+		t.Logf("Unexpected active secrets %v\n", active)
+		t.Fail()
+	}
+}
+
+// mockWebHooks answers the webhook endpoints used by ReconcileWebHooks and
+// records the changes made as "METHOD target" for registrations and
+// "METHOD id[/action]" for the rest. Registered webhooks are active right away.
+func mockWebHooks(existing []WebHook, calls *[]string, registered *[]WebHook) {
+	httpmock.RegisterResponder("GET", "https://api.layer.com/apps/123/webhooks",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, existing)
+		},
+	)
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks",
+		func(req *http.Request) (*http.Response, error) {
+			var w WebHook
+			if err := json.NewDecoder(req.Body).Decode(&w); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			*calls = append(*calls, "POST "+w.TargetURL)
+			if registered != nil {
+				*registered = append(*registered, w)
+			}
+			w.ID = "new"
+			w.Status = WebHookStatusActive
+			return httpmock.NewJsonResponse(201, w)
+		},
+	)
+
+	for _, w := range existing {
+		id := w.ID
+		httpmock.RegisterResponder("DELETE", "https://api.layer.com/apps/123/webhooks/"+id,
+			func(req *http.Request) (*http.Response, error) {
+				*calls = append(*calls, "DELETE "+id)
+				return httpmock.NewStringResponse(204, ""), nil
+			},
+		)
+		for action, status := range map[string]string{"activate": WebHookStatusActive, "deactivate": WebHookStatusInactive} {
+			action, status := action, status
+			httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks/"+id+"/"+action,
+				func(req *http.Request) (*http.Response, error) {
+					*calls = append(*calls, "POST "+id+"/"+action)
+					return httpmock.NewJsonResponse(200, WebHook{ID: id, Status: status})
+				},
+			)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package glare
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -17,43 +20,176 @@ type WebHook struct {
 	Config    map[string]interface{} `json:"config,omitempty"`
 }
 
+// Statuses a WebHook can be in.
+const (
+	WebHookStatusUnverified = "unverified"
+	WebHookStatusActive     = "active"
+	WebHookStatusInactive   = "inactive"
+)
+
+// WebHookEventType identifies the kind of event that caused a webhook to fire.
+// WebHook.Events stays a []string, so the constants are converted with
+// string() when subscribing to them.
+type WebHookEventType string
+
+// Event types sent by Layer webhooks.
+const (
+	WebHookConversationCreated             WebHookEventType = "conversation.created"
+	WebHookConversationUpdated             WebHookEventType = "conversation.updated"
+	WebHookConversationUpdatedParticipants WebHookEventType = "conversation.updated.participants"
+	WebHookConversationUpdatedMetaData     WebHookEventType = "conversation.updated.metadata"
+	WebHookConversationDeleted             WebHookEventType = "conversation.deleted"
+	WebHookMessageSent                     WebHookEventType = "message.sent"
+	WebHookMessageUpdated                  WebHookEventType = "message.updated"
+	WebHookMessageDeleted                  WebHookEventType = "message.deleted"
+	WebHookMessageDelivered                WebHookEventType = "message.delivered"
+	WebHookMessageRead                     WebHookEventType = "message.read"
+	WebHookIdentityCreated                 WebHookEventType = "identity.created"
+	WebHookIdentityUpdated                 WebHookEventType = "identity.updated"
+	WebHookIdentityDeleted                 WebHookEventType = "identity.deleted"
+	WebHookAnnouncementSent                WebHookEventType = "announcement.sent"
+	WebHookAnnouncementDeleted             WebHookEventType = "announcement.deleted"
+)
+
+// Resource returns the kind of resource the event is about, such as
+// "message" or "conversation".
+func (t WebHookEventType) Resource() string {
+	s := string(t)
+	if i := strings.Index(s, "."); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
+
+// IsReceipt reports whether the event is a delivery or read receipt.
+func (t WebHookEventType) IsReceipt() bool {
+	return t == WebHookMessageDelivered || t == WebHookMessageRead
+}
+
+// WebHookActor identifies who caused a webhook event.
+type WebHookActor struct {
+	Name   string `json:"name,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+// WebHookEvent contains information about the event that caused the webhook to fire.
+type WebHookEvent struct {
+	ID        string           `json:"id"`
+	CreatedAt *time.Time       `json:"created_at,omitempty"`
+	Type      WebHookEventType `json:"type"`
+	Actor     WebHookActor     `json:"actor"`
+}
+
+// WebHookChange describes a single property changed by an update event.
+type WebHookChange struct {
+	Operation string      `json:"operation"`
+	Property  string      `json:"property"`
+	Value     interface{} `json:"value,omitempty"`
+	OldValue  interface{} `json:"old_value,omitempty"`
+}
+
 // A WebHookMessagePayload represents the request body sent from a Layer webhook.
 type WebHookMessagePayload struct {
-	Event struct {
-		ID        string     `json:"id"`
-		CreatedAt *time.Time `json:"created_at,omitempty"`
-		Type      string     `json:"type"`
-		Actor     struct {
-			Name   string `json:"name,omitempty"`
-			UserID string `json:"user_id,omitempty"`
-		} `json:"actor"`
-	} `json:"event"`
+	Event   WebHookEvent           `json:"event"`
 	Message Message                `json:"message"`
+	Changes []WebHookChange        `json:"changes,omitempty"`
 	Config  map[string]interface{} `json:"config"`
 }
 
 // A WebHookConversationPayload represents the request body sent from a Layer webhook.
 type WebHookConversationPayload struct {
-	Event struct {
-		ID        string     `json:"id"`
-		CreatedAt *time.Time `json:"created_at,omitempty"`
-		Type      string     `json:"type"`
-		Actor     struct {
-			Name   string `json:"name,omitempty"`
-			UserID string `json:"user_id,omitempty"`
-		} `json:"actor"`
-	} `json:"event"`
+	Event        WebHookEvent           `json:"event"`
 	Conversation Conversation           `json:"conversation"`
+	Changes      []WebHookChange        `json:"changes,omitempty"`
 	Config       map[string]interface{} `json:"config"`
 }
 
-// WebHookEvent contains information about the event that caused the webhook to fire.
-type WebHookEvent struct {
-	ID        string     `json:"id"`
-	CreatedAt *time.Time `json:"created_at,omitempty"`
+// A WebHookIdentityPayload represents the request body sent from a Layer
+// webhook for identity events.
+type WebHookIdentityPayload struct {
+	Event    WebHookEvent           `json:"event"`
+	Identity Identity               `json:"identity"`
+	Changes  []WebHookChange        `json:"changes,omitempty"`
+	Config   map[string]interface{} `json:"config"`
+}
+
+// A WebHookAnnouncementPayload represents the request body sent from a Layer
+// webhook for announcement events.
+type WebHookAnnouncementPayload struct {
+	Event        WebHookEvent           `json:"event"`
+	Announcement Announcement           `json:"announcement"`
+	Config       map[string]interface{} `json:"config"`
+}
+
+// WebHookReceipt describes who marked a message as delivered or read.
+type WebHookReceipt struct {
+	UserID    string     `json:"user_id"`
 	Type      string     `json:"type"`
-	Actor     struct {
-		Name   string `json:"name,omitempty"`
-		UserID string `json:"user_id,omitempty"`
-	} `json:"actor"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// A WebHookReceiptPayload represents the request body sent from a Layer
+// webhook for delivery and read receipts.
+type WebHookReceiptPayload struct {
+	Event   WebHookEvent           `json:"event"`
+	Message Message                `json:"message"`
+	Receipt WebHookReceipt         `json:"receipt"`
+	Config  map[string]interface{} `json:"config"`
+}
+
+// WebHookDelivery is a decoded webhook delivery. Exactly one of the payload
+// fields is set, chosen by the resource of Event.Type, except for event types
+// glare does not know, which only have their Event.
+type WebHookDelivery struct {
+	Event        WebHookEvent
+	Message      *WebHookMessagePayload
+	Conversation *WebHookConversationPayload
+	Identity     *WebHookIdentityPayload
+	Announcement *WebHookAnnouncementPayload
+	Receipt      *WebHookReceiptPayload
+}
+
+// ParseWebHook decodes the body of a webhook delivery into the payload type
+// matching its event. Events of an unknown type are not an error, since Layer
+// may add new ones: the delivery then only holds the event, so that it can be
+// acknowledged and skipped.
+func ParseWebHook(body []byte) (WebHookDelivery, error) {
+	var delivery WebHookDelivery
+	var envelope struct {
+		Event WebHookEvent `json:"event"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return delivery, err
+	}
+	delivery.Event = envelope.Event
+
+	var payload interface{}
+	switch {
+	case envelope.Event.Type.IsReceipt():
+		delivery.Receipt = &WebHookReceiptPayload{}
+		payload = delivery.Receipt
+	case envelope.Event.Type.Resource() == "message":
+		delivery.Message = &WebHookMessagePayload{}
+		payload = delivery.Message
+	case envelope.Event.Type.Resource() == "conversation":
+		delivery.Conversation = &WebHookConversationPayload{}
+		payload = delivery.Conversation
+	case envelope.Event.Type.Resource() == "identity":
+		delivery.Identity = &WebHookIdentityPayload{}
+		payload = delivery.Identity
+	case envelope.Event.Type.Resource() == "announcement":
+		delivery.Announcement = &WebHookAnnouncementPayload{}
+		payload = delivery.Announcement
+	case len(envelope.Event.Type) == 0:
+		return delivery, fmt.Errorf("Webhook delivery is missing an event type")
+	default:
+		return delivery, nil
+	}
+
+	if err := json.Unmarshal(body, payload); err != nil {
+		return delivery, err
+	}
+
+	return delivery, nil
 }
@@ -0,0 +1,51 @@
+package glare
+
+import (
+	"testing"
+)
+
+// TestParseWebHook should decode each delivery into the payload matching its
+// event type, including the changes of update events.
+func TestParseWebHook(t *testing.T) {
+	body := []byte(`{
+		"event": {"id": "e1", "type": "identity.updated", "actor": {"user_id": "frodo"}},
+		"identity": {"id": "layer:///identities/frodo", "user_id": "frodo", "display_name": "Mr. Underhill"},
+		"changes": [{"operation": "set", "property": "display_name", "value": "Mr. Underhill", "old_value": "Frodo"}]
+	}`)
+
+	delivery, err := ParseWebHook(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Identity == nil || delivery.Message != nil || delivery.Conversation != nil {
+		t.Fatalf("Expected only an identity payload, got %+v\n", delivery)
+	}
+
+	if delivery.Event.Actor.UserID != "frodo" || delivery.Identity.Identity.ID.UserID() != "frodo" {
+		t.Logf("Unexpected identity payload %+v\n", delivery.Identity)
+		t.Fail()
+	}
+
+	if len(delivery.Identity.Changes) != 1 || delivery.Identity.Changes[0].OldValue != "Frodo" {
+		t.Logf("Unexpected changes %+v\n", delivery.Identity.Changes)
+		t.Fail()
+	}
+
+	delivery, err = ParseWebHook([]byte(`{"event": {"type": "message.read"}, "receipt": {"user_id": "sam", "type": "read"}}`))
+	if err != nil || delivery.Receipt == nil || delivery.Receipt.Receipt.UserID != "sam" {
+		t.Logf("Unexpected receipt delivery %+v: %v\n", delivery, err)
+		t.Fail()
+	}
+
+	delivery, err = ParseWebHook([]byte(`{"event": {"type": "ring.destroyed"}}`))
+	if err != nil || delivery.Event.Type != "ring.destroyed" || deliveryConversation(delivery) != "" || delivery.Identity != nil || delivery.Announcement != nil {
+		t.Logf("Expected an unknown event type to only hold its event, got %+v: %v\n", delivery, err)
+		t.Fail()
+	}
+
+	if _, err = ParseWebHook([]byte(`{"event": {}}`)); err == nil {
+		t.Log("Expected a missing event type to be rejected")
+		t.Fail()
+	}
+}
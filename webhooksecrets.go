@@ -0,0 +1,104 @@
+package glare
+
+import (
+	"sync"
+	"time"
+)
+
+// WebHookSecrets is a set of webhook secrets that are accepted when verifying
+// deliveries, each valid until its expiry. It lets deliveries signed with
+// either the old or the new secret through while a secret is being rotated.
+// It is safe for concurrent use.
+type WebHookSecrets struct {
+	mu      sync.RWMutex
+	secrets map[string]time.Time
+}
+
+// NewWebHookSecrets returns a set holding the given secrets without expiry.
+func NewWebHookSecrets(secrets ...string) *WebHookSecrets {
+	s := &WebHookSecrets{secrets: make(map[string]time.Time)}
+	for _, secret := range secrets {
+		s.secrets[secret] = time.Time{}
+	}
+
+	return s
+}
+
+// Add accepts the secret until expiresAt, or forever if expiresAt is zero.
+func (s *WebHookSecrets) Add(secret string, expiresAt time.Time) {
+	s.mu.Lock()
+	s.secrets[secret] = expiresAt
+	s.mu.Unlock()
+}
+
+// Remove stops accepting the secret immediately.
+func (s *WebHookSecrets) Remove(secret string) {
+	s.mu.Lock()
+	delete(s.secrets, secret)
+	s.mu.Unlock()
+}
+
+// Active returns the secrets that have not expired yet.
+func (s *WebHookSecrets) Active() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var active []string
+	now := time.Now()
+	for secret, expiresAt := range s.secrets {
+		if expiresAt.IsZero() || now.Before(expiresAt) {
+			active = append(active, secret)
+		}
+	}
+
+	return active
+}
+
+// Verify reports whether header holds a valid signature of the body for any of
+// the active secrets.
+func (s *WebHookSecrets) Verify(body []byte, header string) bool {
+	for _, secret := range s.Active() {
+		if VerifyWebHookSignature(secret, body, header) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RotateWebHookSecret replaces the secret of the given webhook. The new secret
+// is added to secrets once the replacement webhook has been registered and
+// activated, the old webhook is then deleted, and its secret stays valid for
+// the grace period so that deliveries Layer is still retrying are accepted. A
+// new secret is generated when newSecret is empty. The replacement webhook is
+// returned.
+func (l Layer) RotateWebHookSecret(w WebHook, newSecret string, secrets *WebHookSecrets, grace time.Duration, activateTimeout time.Duration) (WebHook, error) {
+	if len(newSecret) == 0 {
+		var err error
+		if newSecret, err = newWebHookSecret(); err != nil {
+			return WebHook{}, err
+		}
+	}
+
+	replacement := w
+	replacement.ID = ""
+	replacement.URL = ""
+	replacement.Status = ""
+	replacement.CreatedAt = nil
+	replacement.Secret = newSecret
+	created, err := l.RegisterAndActivateWebHook(replacement, nil, activateTimeout)
+	if err != nil {
+		return created, err
+	}
+	secrets.Add(newSecret, time.Time{})
+
+	if err = l.DeleteWebHook(w); err != nil {
+		return created, err
+	}
+
+	if len(w.Secret) > 0 && w.Secret != newSecret {
+		secrets.Add(w.Secret, time.Now().Add(grace))
+	}
+
+	return created, nil
+}
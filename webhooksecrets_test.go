@@ -0,0 +1,89 @@
+package glare
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+)
+
+// TestRotateWebHookSecretSuccess should register the replacement webhook with
+// the new secret, delete the old webhook and accept both secrets until the
+// grace period of the old one ends.
+func TestRotateWebHookSecretSuccess(t *testing.T) {
+	var registered WebHook
+	var deleted bool
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks",
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&registered); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			registered.ID = "2"
+			registered.Status = WebHookStatusActive
+			return httpmock.NewJsonResponse(201, registered)
+		},
+	)
+	httpmock.RegisterResponder("DELETE", "https://api.layer.com/apps/123/webhooks/1",
+		func(req *http.Request) (*http.Response, error) {
+			deleted = true
+			return httpmock.NewStringResponse(204, ""), nil
+		},
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	secrets := NewWebHookSecrets("old")
+	old := WebHook{ID: "1", TargetURL: "https://example.com/webhooks", Secret: "old", Status: WebHookStatusActive}
+	webhook, err := l.RotateWebHookSecret(old, "new", secrets, 50*time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if webhook.ID != "2" || registered.Secret != "new" || registered.TargetURL != old.TargetURL || !deleted {
+		t.Logf("Unexpected rotation to %+v, registered %+v, deleted %v\n", webhook, registered, deleted)
+		t.Fail()
+	}
+
+	active := secrets.Active()
+	sort.Strings(active)
+	if len(active) != 2 || active[0] != "new" || active[1] != "old" {
+		t.Logf("Unexpected active secrets %v\n", active)
+		t.Fail()
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	body := []byte(`{"event":{"id":"e1","type":"message.sent"}}`)
+	if secrets.Verify(body, WebHookSignature("old", body)) || !secrets.Verify(body, WebHookSignature("new", body)) {
+		t.Log("Expected only the new secret to be accepted after the grace period")
+		t.Fail()
+	}
+}
+
+// TestRotateWebHookSecretRegisterFailure should leave the secrets untouched
+// when the replacement webhook cannot be registered.
+func TestRotateWebHookSecretRegisterFailure(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://api.layer.com/apps/123/webhooks",
+		httpmock.NewStringResponder(500, ""),
+	)
+
+	l := New("123", "fjghfjshryfbus", "1.0", Backoff{})
+	secrets := NewWebHookSecrets("old")
+	old := WebHook{ID: "1", TargetURL: "https://example.com/webhooks", Secret: "old"}
+	if _, err := l.RotateWebHookSecret(old, "new", secrets, time.Hour, time.Second); err == nil {
+		t.Fatal("Expected the rotation to fail")
+	}
+
+	if active := secrets.Active(); len(active) != 1 || active[0] != "old" {
+		t.Logf("Unexpected active secrets %v\n", active)
+		t.Fail()
+	}
+}
@@ -0,0 +1,73 @@
+package glare
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+)
+
+// WebHookSignatureHeader is the header Layer uses to sign webhook deliveries.
+const WebHookSignatureHeader = "layer-webhook-signature"
+
+// WebHookSignature returns the hex encoded HMAC-SHA1 of the body keyed with the
+// webhook secret, as sent by Layer in the WebHookSignatureHeader.
+func WebHookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebHookSignature reports whether header holds a valid signature of the
+// body for the given webhook secret. The comparison runs in constant time.
+func VerifyWebHookSignature(secret string, body []byte, header string) bool {
+	if len(header) == 0 {
+		return false
+	}
+
+	signature, err := hex.DecodeString(header)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// VerifyWebHookMiddleware wraps next so that it only receives deliveries signed
+// with the given secret. Unsigned or tampered requests are answered with a 403.
+// The body is restored so that next can decode it into a WebHookMessagePayload
+// or WebHookConversationPayload as usual.
+func VerifyWebHookMiddleware(secret string, next http.Handler) http.Handler {
+	return verifyMiddleware(func(body []byte, header string) bool {
+		return VerifyWebHookSignature(secret, body, header)
+	}, next)
+}
+
+// VerifyWebHookSecretsMiddleware is like VerifyWebHookMiddleware but accepts a
+// delivery signed with any of the active secrets.
+func VerifyWebHookSecretsMiddleware(secrets *WebHookSecrets, next http.Handler) http.Handler {
+	return verifyMiddleware(secrets.Verify, next)
+}
+
+func verifyMiddleware(verify func(body []byte, header string) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !verify(body, r.Header.Get(WebHookSignatureHeader)) {
+			http.Error(w, "invalid webhook signature", http.StatusForbidden)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
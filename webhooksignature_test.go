@@ -0,0 +1,68 @@
+package glare
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestVerifyWebHookMiddleware should only pass correctly signed deliveries on
+// to the wrapped handler.
+func TestVerifyWebHookMiddleware(t *testing.T) {
+	body := []byte(`{"event":{"type":"message.sent"}}`)
+	var received []byte
+	handler := VerifyWebHookMiddleware("shhh", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r.Body)
+		received = buf.Bytes()
+	}))
+
+	cases := []struct {
+		body      []byte
+		signature string
+		status    int
+	}{
+		{body, WebHookSignature("shhh", body), http.StatusOK},
+		{body, "", http.StatusForbidden},
+		{body, WebHookSignature("wrong", body), http.StatusForbidden},
+		{[]byte(`{"event":{"type":"message.deleted"}}`), WebHookSignature("shhh", body), http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		received = nil
+		req := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(c.body))
+		if len(c.signature) > 0 {
+			req.Header.Set(WebHookSignatureHeader, c.signature)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != c.status {
+			t.Logf("Expected status %d, got %d for signature %q\n", c.status, rec.Code, c.signature)
+			t.Fail()
+		}
+
+		if c.status == http.StatusOK && !bytes.Equal(received, c.body) {
+			t.Logf("Handler received %q\n", received)
+			t.Fail()
+		}
+	}
+}
+
+// TestWebHookSecretsVerify should accept deliveries signed with any active
+// secret and reject those signed with an expired one.
+func TestWebHookSecretsVerify(t *testing.T) {
+	body := []byte(`{"event":{"type":"message.sent"}}`)
+	secrets := NewWebHookSecrets("new")
+	secrets.Add("old", time.Now().Add(time.Hour))
+	secrets.Add("retired", time.Now().Add(-time.Second))
+
+	for secret, valid := range map[string]bool{"new": true, "old": true, "retired": false, "unknown": false} {
+		if secrets.Verify(body, WebHookSignature(secret, body)) != valid {
+			t.Logf("Expected signature with secret %q to be valid: %t\n", secret, valid)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package glare
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebHookSimulator posts correctly signed webhook deliveries to a target, so
+// webhook consumers can be exercised without a real Layer app.
+type WebHookSimulator struct {
+	TargetURL string
+	Secret    string
+	// Client is used to post deliveries. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// RecordedWebHook is a delivery captured by a WebHookRecorder.
+type RecordedWebHook struct {
+	ReceivedAt time.Time         `json:"received_at"`
+	Headers    map[string]string `json:"headers"`
+	Body       json.RawMessage   `json:"body"`
+}
+
+// SimulateMessage delivers a message event of the given type for the message.
+func (s WebHookSimulator) SimulateMessage(eventType WebHookEventType, m Message) error {
+	return s.simulate(WebHookMessagePayload{Event: newSimulatedEvent(eventType), Message: m})
+}
+
+// SimulateConversation delivers a conversation event of the given type for the
+// conversation.
+func (s WebHookSimulator) SimulateConversation(eventType WebHookEventType, c Conversation) error {
+	return s.simulate(WebHookConversationPayload{Event: newSimulatedEvent(eventType), Conversation: c})
+}
+
+// SimulateLiveMessage fetches the message from Layer and delivers it as a
+// message.sent event.
+func (s WebHookSimulator) SimulateLiveMessage(l Layer, id MessageID) error {
+	m, err := l.GetMessage(id)
+	if err != nil {
+		return err
+	}
+
+	return s.SimulateMessage(WebHookMessageSent, m)
+}
+
+// SimulateLiveConversation fetches the conversation from Layer and delivers it
+// as a conversation.created event.
+func (s WebHookSimulator) SimulateLiveConversation(l Layer, id ConversationID) error {
+	c, err := l.GetConversationByID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.SimulateConversation(WebHookConversationCreated, c)
+}
+
+// DeliverFile signs and delivers the JSON payload stored in the fixture file.
+func (s WebHookSimulator) DeliverFile(path string) error {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return s.Deliver(body)
+}
+
+// Deliver signs the raw delivery body with the simulator's secret and posts it
+// to the target. Any response outside the 2xx range is returned as an error.
+func (s WebHookSimulator) Deliver(body []byte) error {
+	req, err := http.NewRequest("POST", s.TargetURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebHookSignatureHeader, WebHookSignature(s.Secret, body))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%d: %s", res.StatusCode, string(msg))
+	}
+
+	return nil
+}
+
+// Replay re-signs and delivers every delivery recorded in dir, in the order they
+// were received. It returns the number of deliveries sent.
+func (s WebHookSimulator) Replay(dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	var recorded []RecordedWebHook
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return 0, err
+		}
+
+		var r RecordedWebHook
+		if err = json.Unmarshal(buf, &r); err != nil {
+			return 0, fmt.Errorf("Unable to read recorded webhook %s: %s", file, err)
+		}
+		recorded = append(recorded, r)
+	}
+
+	sort.SliceStable(recorded, func(i, j int) bool {
+		return recorded[i].ReceivedAt.Before(recorded[j].ReceivedAt)
+	})
+
+	for i, r := range recorded {
+		if err = s.Deliver(r.Body); err != nil {
+			return i, err
+		}
+	}
+
+	return len(recorded), nil
+}
+
+func (s WebHookSimulator) simulate(payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return s.Deliver(body)
+}
+
+// newSimulatedEvent returns an event of the given type with a random ID.
+func newSimulatedEvent(eventType WebHookEventType) WebHookEvent {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	now := time.Now().UTC()
+
+	return WebHookEvent{
+		ID:        "layer:///events/" + hex.EncodeToString(buf),
+		CreatedAt: &now,
+		Type:      eventType,
+	}
+}
+
+// redactedWebHookHeaders are the headers a WebHookRecorder does not save.
+var redactedWebHookHeaders = []string{"Authorization", "Cookie", WebHookSignatureHeader}
+
+// WebHookRecorder is an http.Handler that saves every delivery it receives as a
+// JSON file in Dir so it can later be replayed with WebHookSimulator.Replay.
+// Deliveries are passed on to Next when it is set and only saved once Next
+// has accepted them with a 2xx, so that forged or rejected deliveries are
+// never replayed; without Next they are acknowledged with a 200. Credentials
+// and signatures are redacted from the saved headers.
+type WebHookRecorder struct {
+	Dir  string
+	Next http.Handler
+
+	mu  sync.Mutex
+	seq int
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (rec *WebHookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		if rec.Next != nil {
+			rec.Next.ServeHTTP(w, r)
+		} else {
+			answerChallenge(w, r)
+		}
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if rec.Next == nil {
+		if err = rec.record(r, body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// The response of Next is held back until the delivery is saved, so that
+	// Layer retries it when saving fails.
+	res := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	rec.Next.ServeHTTP(res, r)
+
+	if res.status >= 200 && res.status <= 299 {
+		if err = rec.record(r, body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for name, values := range res.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(res.status)
+	w.Write(res.body.Bytes())
+}
+
+func (rec *WebHookRecorder) record(r *http.Request, body []byte) error {
+	if !json.Valid(body) {
+		return fmt.Errorf("Webhook delivery is not valid JSON")
+	}
+
+	recorded := RecordedWebHook{
+		ReceivedAt: time.Now().UTC(),
+		Headers:    make(map[string]string),
+		Body:       body,
+	}
+	for name := range r.Header {
+		recorded.Headers[name] = r.Header.Get(name)
+		for _, redacted := range redactedWebHookHeaders {
+			if strings.EqualFold(name, redacted) {
+				recorded.Headers[name] = "REDACTED"
+			}
+		}
+	}
+
+	buf, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(rec.Dir, 0700); err != nil {
+		return err
+	}
+
+	rec.mu.Lock()
+	rec.seq++
+	name := fmt.Sprintf("%s-%04d.json", recorded.ReceivedAt.Format("20060102T150405.000000000"), rec.seq)
+	rec.mu.Unlock()
+
+	return ioutil.WriteFile(filepath.Join(rec.Dir, name), buf, 0600)
+}
+
+// bufferedResponse is an http.ResponseWriter that keeps the response in
+// memory.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,84 @@
+package glare
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWebHookSimulatorRecordAndReplay should deliver signed payloads accepted
+// by a WebHookHandler, record them and replay the recordings.
+func TestWebHookSimulatorRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glare-recordings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var received []WebHookEventType
+	handler := &WebHookHandler{
+		Secret: "shhh",
+		OnMessageSent: func(ctx context.Context, p WebHookMessagePayload) error {
+			received = append(received, p.Event.Type)
+			return nil
+		},
+		OnConversationCreated: func(ctx context.Context, p WebHookConversationPayload) error {
+			received = append(received, p.Event.Type)
+			return nil
+		},
+	}
+	server := httptest.NewServer(&WebHookRecorder{Dir: dir, Next: handler})
+	defer server.Close()
+
+	sim := WebHookSimulator{TargetURL: server.URL, Secret: "shhh"}
+	if err = sim.SimulateConversation(WebHookConversationCreated, Conversation{ID: "layer:///conversations/f3cc7b32-3c92-11e4-baad-164230d1df67"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sim.SimulateMessage(WebHookMessageSent, Message{ID: "layer:///messages/940de862-3c96-11e4-baad-164230d1df67"}); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := sim.Replay(dir)
+	if err != nil || replayed != 2 {
+		t.Fatalf("Expected two deliveries replayed, got %d: %v\n", replayed, err)
+	}
+
+	if err = (WebHookSimulator{TargetURL: server.URL, Secret: "wrong"}).SimulateMessage(WebHookMessageSent, Message{}); err == nil {
+		t.Log("Expected a delivery signed with the wrong secret to be rejected")
+		t.Fail()
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 4 {
+		t.Fatalf("Expected only the four accepted deliveries to be recorded, got %d: %v\n", len(files), err)
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded RecordedWebHook
+	if err = json.Unmarshal(buf, &recorded); err != nil || recorded.Headers[http.CanonicalHeaderKey(WebHookSignatureHeader)] != "REDACTED" {
+		t.Logf("Expected the signature to be redacted from %s: %v\n", buf, err)
+		t.Fail()
+	}
+
+	expected := []WebHookEventType{WebHookConversationCreated, WebHookMessageSent, WebHookConversationCreated, WebHookMessageSent}
+	if len(received) != len(expected) {
+		t.Fatalf("Unexpected deliveries %v\n", received)
+	}
+
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Logf("Unexpected deliveries %v\n", received)
+			t.Fail()
+			break
+		}
+	}
+}